@@ -0,0 +1,190 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//BufferStats describes the state of a buffer
+type BufferStats struct {
+	ServiceName   string    `json:"serviceName"`
+	ServiceInfo   string    `json:"serviceInfo"`
+	Entries       int       `json:"entries"`
+	Bytes         int       `json:"bytes"`
+	ErrorHappened bool      `json:"errorHappened"`
+	LastWrite     time.Time `json:"lastWrite"`
+	LastFlush     time.Time `json:"lastFlush"`
+}
+
+//Stats returns the current state of the buffer
+func (logFile *LFile) Stats() BufferStats {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+
+	return BufferStats{
+		ServiceName:   logFile.serviceName,
+		ServiceInfo:   logFile.serviceInfo,
+		Entries:       logFile.buffer.len(),
+		Bytes:         logFile.buffer.size,
+		ErrorHappened: logFile.errorHappened,
+		LastWrite:     logFile.lastWrite,
+		LastFlush:     logFile.lastFlush,
+	}
+}
+
+//Records returns a copy of the buffered records from oldest to newest
+func (logFile *LFile) Records() []Record {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	return logFile.buffer.snapshot()
+}
+
+//NewAdminHandler returns an http.Handler to inspect and control the buffers of r
+//It is meant for a local admin port, mount it under a prefix with http.StripPrefix:
+//
+//	mux.Handle("/debug/buffers/", http.StripPrefix("/debug/buffers", log.NewAdminHandler(log.DefaultRegistry())))
+//
+//The routes are
+//
+//	GET  /buffers                        the stats of every buffer
+//	GET  /buffers/{service}/{info}       the stats and records of a buffer
+//	POST /buffers/{service}/{info}/flush flush a buffer, also without pending errors
+//	POST /buffers/{service}/{info}/clear drop the entries of a buffer
+//	PUT  /buffers/{service}/{info}/trigger?level=warning
+//	                                     replace the trigger policy of a buffer by a LevelTrigger
+//	PUT  /config?max_buffers=100         change the amount of buffers the registry holds
+func NewAdminHandler(r *Registry) http.Handler {
+	return &adminHandler{registry: r}
+}
+
+//adminHandler routes the admin requests by hand, so it works without the pattern matching of newer ServeMux versions
+type adminHandler struct {
+	registry *Registry
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "buffers":
+		if allowMethod(w, req, http.MethodGet) {
+			h.list(w)
+		}
+	case len(parts) == 1 && parts[0] == "config":
+		if allowMethod(w, req, http.MethodPut) {
+			h.config(w, req)
+		}
+	case (len(parts) == 3 || len(parts) == 4) && parts[0] == "buffers":
+		logFile, _ := h.registry.GetLogBufferAndLogger(parts[1], parts[2])
+		if logFile == nil {
+			http.Error(w, "buffer not found", http.StatusNotFound)
+			return
+		}
+
+		action := ""
+		if len(parts) == 4 {
+			action = parts[3]
+		}
+		switch action {
+		case "":
+			if allowMethod(w, req, http.MethodGet) {
+				h.show(w, logFile)
+			}
+		case "flush":
+			if allowMethod(w, req, http.MethodPost) {
+				h.flush(w, logFile)
+			}
+		case "clear":
+			if allowMethod(w, req, http.MethodPost) {
+				logFile.Clear()
+				writeJSON(w, http.StatusOK, logFile.Stats())
+			}
+		case "trigger":
+			if allowMethod(w, req, http.MethodPut) {
+				h.trigger(w, req, logFile)
+			}
+		default:
+			http.NotFound(w, req)
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+//list responds with the stats of every registered buffer
+func (h *adminHandler) list(w http.ResponseWriter) {
+	buffers := h.registry.registered()
+	stats := make([]BufferStats, len(buffers))
+	for i, logFile := range buffers {
+		stats[i] = logFile.Stats()
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+//show responds with the stats and the records of a buffer
+func (h *adminHandler) show(w http.ResponseWriter, logFile *LFile) {
+	records := logFile.Records()
+	data := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		data[i] = record.Data()
+	}
+	writeJSON(w, http.StatusOK, struct {
+		BufferStats
+		Records []map[string]interface{} `json:"records"`
+	}{logFile.Stats(), data})
+}
+
+//flush sends the buffer to its sink, whether or not an error happened
+func (h *adminHandler) flush(w http.ResponseWriter, logFile *LFile) {
+	logFile.MarkError()
+	result, err := logFile.Flush()
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{"result": result, "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+//trigger replaces the trigger policy of a buffer by a LevelTrigger for the requested level
+func (h *adminHandler) trigger(w http.ResponseWriter, req *http.Request, logFile *LFile) {
+	level, err := logrus.ParseLevel(req.FormValue("level"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logFile.SetTriggerPolicy(TriggerPolicy{LevelTrigger(level)})
+	writeJSON(w, http.StatusOK, map[string]string{"level": level.String()})
+}
+
+//config changes the amount of buffers the registry holds
+func (h *adminHandler) config(w http.ResponseWriter, req *http.Request) {
+	maxBuffers, err := strconv.Atoi(req.FormValue("max_buffers"))
+	if err != nil || maxBuffers <= 0 {
+		http.Error(w, "max_buffers must be a positive number", http.StatusBadRequest)
+		return
+	}
+	h.registry.SetMaxBuffers(maxBuffers)
+	writeJSON(w, http.StatusOK, map[string]int{"max_buffers": maxBuffers, "buffers": h.registry.Len()})
+}
+
+//allowMethod responds 405 and returns false if req does not use method
+func allowMethod(w http.ResponseWriter, req *http.Request, method string) bool {
+	if req.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+//writeJSON responds with value encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
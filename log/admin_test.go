@@ -0,0 +1,222 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+//newAdminTest creates a registry with the buffers svc/api and svc/worker, both holding one entry, and its admin handler
+func newAdminTest(t *testing.T) (*Registry, http.Handler, *MemorySink) {
+	t.Helper()
+	sink := &MemorySink{}
+	registry := NewRegistry(0)
+	for _, info := range []string{"api", "worker"} {
+		_, logger := registry.CreateLogBuffer("svc", info, 0, "", WithSink(sink))
+		logger.Logger.SetOutput(ioutil.Discard)
+		logger.Info("hello from ", info)
+	}
+	return registry, NewAdminHandler(registry), sink
+}
+
+//serve sends a request to handler and returns the recorded response
+func serve(handler http.Handler, method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestAdminHandlerRouting(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		status int
+		allow  string
+	}{
+		{"GET", "/buffers", http.StatusOK, ""},
+		{"GET", "/buffers/", http.StatusOK, ""},
+		{"POST", "/buffers", http.StatusMethodNotAllowed, "GET"},
+		{"GET", "/buffers/svc/api", http.StatusOK, ""},
+		{"DELETE", "/buffers/svc/api", http.StatusMethodNotAllowed, "GET"},
+		{"GET", "/buffers/svc/missing", http.StatusNotFound, ""},
+		{"POST", "/buffers/svc/missing/flush", http.StatusNotFound, ""},
+		{"GET", "/buffers/svc/api/flush", http.StatusMethodNotAllowed, "POST"},
+		{"GET", "/buffers/svc/api/clear", http.StatusMethodNotAllowed, "POST"},
+		{"POST", "/buffers/svc/api/trigger?level=warning", http.StatusMethodNotAllowed, "PUT"},
+		{"POST", "/buffers/svc/api/unknown", http.StatusNotFound, ""},
+		{"GET", "/buffers/svc", http.StatusNotFound, ""},
+		{"GET", "/buffers/svc/api/flush/now", http.StatusNotFound, ""},
+		{"GET", "/config?max_buffers=5", http.StatusMethodNotAllowed, "PUT"},
+		{"GET", "/", http.StatusNotFound, ""},
+		{"GET", "/other", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.method+" "+test.target, func(t *testing.T) {
+			_, handler, _ := newAdminTest(t)
+			w := serve(handler, test.method, test.target)
+			if w.Code != test.status {
+				t.Errorf("status %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if got := w.Header().Get("Allow"); got != test.allow {
+				t.Errorf("Allow %q, want %q", got, test.allow)
+			}
+		})
+	}
+}
+
+func TestAdminHandlerList(t *testing.T) {
+	_, handler, _ := newAdminTest(t)
+
+	w := serve(handler, "GET", "/buffers")
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type %q", got)
+	}
+	var stats []BufferStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	var infos []string
+	for _, s := range stats {
+		if s.ServiceName != "svc" || s.Entries != 1 || s.Bytes == 0 || s.ErrorHappened {
+			t.Errorf("listed %+v", s)
+		}
+		infos = append(infos, s.ServiceInfo)
+	}
+	if want := []string{"api", "worker"}; !reflect.DeepEqual(infos, want) {
+		t.Errorf("listed %v, want %v", infos, want)
+	}
+}
+
+func TestAdminHandlerShow(t *testing.T) {
+	_, handler, _ := newAdminTest(t)
+
+	var shown struct {
+		BufferStats
+		Records []map[string]interface{} `json:"records"`
+	}
+	if err := json.Unmarshal(serve(handler, "GET", "/buffers/svc/api").Body.Bytes(), &shown); err != nil {
+		t.Fatal(err)
+	}
+	if shown.ServiceInfo != "api" || shown.Entries != 1 || len(shown.Records) != 1 {
+		t.Fatalf("showed %+v", shown)
+	}
+	if got := shown.Records[0]["msg"]; got != "hello from api" {
+		t.Errorf("record message %v", got)
+	}
+}
+
+func TestAdminHandlerFlush(t *testing.T) {
+	registry, handler, sink := newAdminTest(t)
+
+	w := serve(handler, "POST", "/buffers/svc/api/flush")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var result FlushResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Lines != 1 {
+		t.Errorf("flushed %d lines, want 1", result.Lines)
+	}
+	if got, want := messages(sink.Records()), []string{"hello from api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sink received %v, want %v", got, want)
+	}
+	logFile, _ := registry.GetLogBufferAndLogger("svc", "api")
+	if logFile.ErrorHappened() || len(logFile.Records()) != 0 {
+		t.Errorf("buffer after the flush: %+v", logFile.Stats())
+	}
+}
+
+func TestAdminHandlerFlushFailure(t *testing.T) {
+	registry := NewRegistry(0)
+	_, logger := registry.CreateLogBuffer("svc", "api", 0, "", WithSink(&partialSink{}))
+	logger.Logger.SetOutput(ioutil.Discard)
+	logger.Info("a")
+
+	w := serve(NewAdminHandler(registry), "POST", "/buffers/svc/api/flush")
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status %d, want %d: %s", w.Code, http.StatusBadGateway, w.Body)
+	}
+}
+
+func TestAdminHandlerClear(t *testing.T) {
+	registry, handler, sink := newAdminTest(t)
+	logFile, _ := registry.GetLogBufferAndLogger("svc", "api")
+	logFile.MarkError()
+
+	w := serve(handler, "POST", "/buffers/svc/api/clear")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var stats BufferStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 0 || stats.Bytes != 0 || stats.ErrorHappened {
+		t.Errorf("stats after clear %+v", stats)
+	}
+	if len(sink.Records()) != 0 {
+		t.Errorf("clear sent %v", messages(sink.Records()))
+	}
+	if other, _ := registry.GetLogBufferAndLogger("svc", "worker"); len(other.Records()) != 1 {
+		t.Error("clear touched another buffer")
+	}
+}
+
+func TestAdminHandlerTrigger(t *testing.T) {
+	registry, handler, _ := newAdminTest(t)
+	logFile, logger := registry.GetLogBufferAndLogger("svc", "api")
+
+	if w := serve(handler, "PUT", "/buffers/svc/api/trigger?level=loud"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown level: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	logger.Warn("before")
+	if logFile.ErrorHappened() {
+		t.Fatal("warning marked the buffer before the trigger changed")
+	}
+
+	w := serve(handler, "PUT", "/buffers/svc/api/trigger?level=warning")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["level"] != "warning" {
+		t.Errorf("responded %v", body)
+	}
+	logger.Warn("after")
+	if !logFile.ErrorHappened() {
+		t.Error("warning did not mark the buffer after the trigger changed")
+	}
+}
+
+func TestAdminHandlerConfig(t *testing.T) {
+	registry, handler, _ := newAdminTest(t)
+
+	for _, target := range []string{"/config", "/config?max_buffers=0", "/config?max_buffers=many"} {
+		if w := serve(handler, "PUT", target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", target, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	w := serve(handler, "PUT", "/config?max_buffers=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body map[string]int
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["max_buffers"] != 1 || body["buffers"] != 1 || registry.Len() != 1 {
+		t.Errorf("responded %v with %d buffers registered", body, registry.Len())
+	}
+	if logFile, _ := registry.GetLogBufferAndLogger("svc", "worker"); logFile == nil {
+		t.Error("newest buffer was evicted")
+	}
+}
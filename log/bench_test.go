@@ -0,0 +1,67 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+//BenchmarkLogConsoleOnly is the baseline, a logrus logger formatting every entry as JSON without a buffer
+func BenchmarkLogConsoleOnly(b *testing.B) {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(ioutil.Discard)
+	entry := logrus.NewEntry(logger).WithFields(logrus.Fields{"request": 42, "path": "/orders"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry.Info("handling request")
+	}
+}
+
+//BenchmarkLogFormattedIntoBuffer is the path the buffer replaced, every entry formatted as JSON once for both the console and a bytes.Buffer
+func BenchmarkLogFormattedIntoBuffer(b *testing.B) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(io.MultiWriter(ioutil.Discard, &buffer))
+	entry := logrus.NewEntry(logger).WithFields(logrus.Fields{"request": 42, "path": "/orders"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry.Info("handling request")
+		//Stands in for a flush, so the buffer does not grow for the whole run
+		if buffer.Len() > 1<<20 {
+			buffer.Reset()
+		}
+	}
+}
+
+//BenchmarkLogBufferOnly logs lines below the console level, they are stored in the buffer without being formatted
+func BenchmarkLogBufferOnly(b *testing.B) {
+	_, logger, _ := newTestBuffer(b, WithBufferLevel(logrus.DebugLevel))
+	entry := logger.WithFields(logrus.Fields{"request": 42, "path": "/orders"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry.Debug("handling request")
+	}
+}
+
+//BenchmarkLogConsoleAndBuffer logs lines that are printed and stored in the buffer
+func BenchmarkLogConsoleAndBuffer(b *testing.B) {
+	_, logger, _ := newTestBuffer(b)
+	entry := logger.WithFields(logrus.Fields{"request": 42, "path": "/orders"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry.Info("handling request")
+	}
+}
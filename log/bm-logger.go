@@ -1,138 +1,464 @@
 package log
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
+	"sync"
 	"time"
 
-	"github.com/fluent/fluent-logger-golang/fluent"
 	"github.com/sirupsen/logrus"
 )
 
 //LFile is an exported struct with a the buffer to which logs are written and extra info for making a write file
+//An LFile is always handled through a pointer, so every holder shares the same buffer and error state
 type LFile struct {
-	buffer        *bytes.Buffer
+	//mu guards buffer, errorHappened, triggers, lastWrite, lastFlush, registry and the streaming state
+	mu            sync.Mutex
+	buffer        *ringBuffer
+	lastWrite     time.Time
+	lastFlush     time.Time
+	registry      *Registry
 	serviceName   string
 	serviceInfo   string
 	errorHappened bool
+	triggers      TriggerPolicy
 	port          int
 	host          string
+	sink          Sink
+	entry         *logrus.Entry
+	//config is kept to create child buffers with the same settings
+	config bufferConfig
+	//streaming is set while the entries of the post-trigger window are sent straight to the sink
+	streaming   bool
+	streamLeft  int
+	streamUntil time.Time
+	//streamQueue holds the streamed entries a goroutine has yet to send, streamSending is set while it runs
+	streamQueue   []Record
+	streamDropped int
+	streamSending bool
+	streamIdle    *sync.Cond
+	//escalated is set while the logger runs at the escalation level, baseLevel is the level to return to
+	escalated      bool
+	baseLevel      logrus.Level
+	escalatedUntil time.Time
+	escalation     *time.Timer
 }
 
-var (
-	//MaxNumberOfBuffers var
-	MaxNumberOfBuffers = 300
-	bufSlice           = []LFile{}
-	entrySlice         = []*logrus.Entry{}
-)
+//Capture stores record in the buffer and marks the buffer if the trigger policy matches it
+//It is the entry point for loggers other than the logrus logger of the buffer
+func (logFile *LFile) Capture(record Record) {
+	logFile.CaptureTrigger(record, false)
+}
 
-//Flush flushes the buffer to the file which will be send to Loki via Fluentd
-func (logFile LFile) Flush() {
-	//Only flush if error has occurred
-	if logFile.errorHappened {
-		start := time.Now()
+//CaptureTrigger is Capture for adapters with a trigger of their own, like a level threshold
+//record is a trigger when isTrigger is set or the trigger policy matches it. It reports whether record triggered,
+//and whether record was already sent to the sink, at the start of or inside a post-trigger window
+func (logFile *LFile) CaptureTrigger(record Record, isTrigger bool) (triggered bool, sent bool) {
+	logFile.mu.Lock()
+	triggers := logFile.triggers
+	logFile.mu.Unlock()
+	triggered = triggers.Match(record) || isTrigger
 
-		//Tag for Loki, easily filterable in Grafana
-		tag := logFile.serviceName + "." + logFile.serviceInfo
-
-		fluent := initFluent(logFile.port, logFile.host)
-
-		//Close the fluent connection
-		defer fluent.Close()
-
-		//Iterate through the buffer using a scanner
-		scanner := bufio.NewScanner(logFile.buffer)
-		for scanner.Scan() {
-			data := scanner.Text()
-			log := make(map[string]interface{})
-
-			//Unmarshal data into log
-			err := json.Unmarshal([]byte(data), &log)
-			if err != nil {
-				logrus.Error("Unmarshalling error", err)
-			}
-			//Send every line to Fluentd
-			error := fluent.Post(tag, log)
-			if error != nil {
-				panic(error)
-			}
+	now := time.Now()
+	logFile.mu.Lock()
+	logFile.lastWrite = now
+
+	//Inside the post-trigger window entries pass through to the sink
+	if logFile.takeStreamSlot(now) {
+		if triggered {
+			logFile.startStreaming(now)
+		}
+		logFile.queueStream(record)
+		logFile.mu.Unlock()
+		if triggered {
+			logFile.escalate()
+		}
+		return triggered, true
+	}
+
+	before := logFile.buffer.size
+	logFile.buffer.push(record)
+	//The first trigger since the last flush decides which context before it is kept
+	if triggered && !logFile.errorHappened {
+		window := logFile.config.preTrigger
+		var since time.Time
+		if window.duration > 0 {
+			since = record.Time.Add(-window.duration)
 		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintln(os.Stderr, "reading standard input:", err)
+		logFile.buffer.trimContext(window.entries, since)
+	}
+	delta := logFile.buffer.size - before
+	registry := logFile.registry
+	if registry != nil {
+		registry.account(delta)
+	}
+	flushNow := triggered && logFile.config.streamWindow().enabled()
+	if flushNow {
+		logFile.errorHappened = true
+		logFile.startStreaming(now)
+	}
+	logFile.mu.Unlock()
+
+	//Reclaim after unlocking, the registry may evict from this buffer
+	if registry != nil && delta > 0 {
+		registry.reclaim()
+	}
+	if flushNow {
+		logFile.escalate()
+		if _, err := logFile.Flush(); err != nil {
+			Diagnostics.Error(err)
 		}
+	} else if triggered {
+		logFile.MarkError()
+	}
+	return triggered, flushNow
+}
 
-		//Get amount of log lines
-		n := logFile.buffer.Len()
+//startStreaming opens the post-trigger window at now, logFile.mu must be held
+func (logFile *LFile) startStreaming(now time.Time) {
+	window := logFile.config.streamWindow()
+	logFile.streaming = true
+	logFile.streamLeft = window.entries
+	logFile.streamUntil = time.Time{}
+	if window.duration > 0 {
+		logFile.streamUntil = now.Add(window.duration)
+	}
+}
 
-		logrus.Printf("Copied %v logs\n", n)
+//takeStreamSlot reports whether an entry captured at now falls in the post-trigger window and counts it
+//The window is closed once a limit is reached, logFile.mu must be held
+func (logFile *LFile) takeStreamSlot(now time.Time) bool {
+	if !logFile.streaming {
+		return false
+	}
+	if !logFile.streamUntil.IsZero() && now.After(logFile.streamUntil) {
+		logFile.streaming = false
+		return false
+	}
+	if logFile.config.streamWindow().entries > 0 {
+		if logFile.streamLeft == 0 {
+			logFile.streaming = false
+			return false
+		}
+		logFile.streamLeft--
+	}
+	return true
+}
 
-		//Reset buffer
-		logFile.buffer.Reset()
+//escalate drops the logger to the escalation level until the cooldown ends, a trigger during the cooldown restarts it
+func (logFile *LFile) escalate() {
+	cooldown := logFile.config.escalateFor
+	if cooldown <= 0 {
+		return
+	}
+	logger := logFile.entry.Logger
 
-		//Calculate flush time
-		logrus.WithFields(
-			logrus.Fields{
-				"serviceName": logFile.serviceName,
-				"serviceInfo": logFile.serviceInfo,
-			}).Info("Flushing took: ", time.Since(start))
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	if !logFile.escalated {
+		logFile.escalated = true
+		logFile.baseLevel = logger.GetLevel()
+		//Never make the logger less verbose than it was configured
+		if logFile.config.escalateLevel > logFile.baseLevel {
+			logger.SetLevel(logFile.config.escalateLevel)
+		}
+	}
+	logFile.escalatedUntil = time.Now().Add(cooldown)
+	if logFile.escalation == nil {
+		logFile.escalation = time.AfterFunc(cooldown, logFile.restoreLevel)
 	} else {
-		logrus.WithFields(
+		logFile.escalation.Reset(cooldown)
+	}
+}
+
+//restoreLevel returns the logger to its level from before the escalation once the cooldown has ended
+func (logFile *LFile) restoreLevel() {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	//A trigger restarted the cooldown after this timer fired
+	if !logFile.escalated || time.Now().Before(logFile.escalatedUntil) {
+		return
+	}
+	logFile.escalated = false
+	logFile.entry.Logger.SetLevel(logFile.baseLevel)
+}
+
+//level returns the level of the logger, or the level it returns to while escalated
+func (logFile *LFile) level() logrus.Level {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	if logFile.escalated {
+		return logFile.baseLevel
+	}
+	return logFile.entry.Logger.GetLevel()
+}
+
+//MaxStreamQueue bounds the entries of a post-trigger window waiting to be sent per buffer
+//Entries streamed while the queue is full are dropped, so a slow sink never blocks the logger
+var MaxStreamQueue = 1000
+
+//queueStream queues an entry of the post-trigger window for the sink, logFile.mu must be held
+//The entries are sent in batches by a goroutine, which runs as long as the queue is not empty
+func (logFile *LFile) queueStream(record Record) {
+	if len(logFile.streamQueue) >= MaxStreamQueue {
+		logFile.streamDropped++
+		return
+	}
+	logFile.streamQueue = append(logFile.streamQueue, record)
+	if !logFile.streamSending {
+		logFile.streamSending = true
+		go logFile.sendStream()
+	}
+}
+
+//sendStream sends the queued entries of the post-trigger window, every entry queued during a send goes in the next batch
+func (logFile *LFile) sendStream() {
+	for {
+		logFile.mu.Lock()
+		records := logFile.streamQueue
+		dropped := logFile.streamDropped
+		logFile.streamQueue = nil
+		logFile.streamDropped = 0
+		if len(records) == 0 {
+			logFile.streamSending = false
+			logFile.streamIdle.Broadcast()
+			logFile.mu.Unlock()
+			return
+		}
+		logFile.mu.Unlock()
+
+		fields := logrus.Fields{
+			"serviceName": logFile.serviceName,
+			"serviceInfo": logFile.serviceInfo,
+		}
+		if dropped > 0 {
+			Diagnostics.WithFields(fields).Warn("Streaming after trigger dropped entries: ", dropped)
+		}
+		start := time.Now()
+		if _, err := logFile.sink.Send(logFile.batch(records, FlushReasonPostTrigger)); err != nil {
+			Diagnostics.WithFields(fields).Error("Streaming after trigger failed: ", err)
+		}
+
+		logFile.mu.Lock()
+		logFile.lastFlush = start
+		logFile.mu.Unlock()
+	}
+}
+
+//WaitStreamed blocks until the entries of the post-trigger window logged so far were handed to the sink
+func (logFile *LFile) WaitStreamed() {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	for logFile.streamSending {
+		logFile.streamIdle.Wait()
+	}
+}
+
+var (
+	//MaxNumberOfBuffers var
+	MaxNumberOfBuffers = 300
+
+	//Diagnostics is the logger the package reports its own work to, like flush times and failed flushes
+	//It is a separate logger, so these lines never end up in a buffer whose LFile is a hook on another logger,
+	//and a flush from inside such a hook does not wait for the mutex of the logger that fired it
+	Diagnostics = logrus.New()
+)
+
+//FlushResult describes what a Flush did with the buffered lines
+type FlushResult struct {
+	//Lines is the amount of lines that were sent
+	Lines int
+	//Failed is the amount of lines that could not be sent
+	Failed int
+	//Bytes is the estimated size of the records that were taken from the buffer
+	Bytes int
+	//Duration is the time it took to flush
+	Duration time.Duration
+	//Destination is where the sink sent the lines to
+	Destination string
+}
+
+//Flush flushes the buffer to its sink, by default the fluentd which forwards to Loki
+//Nothing is sent if no error happened since the last flush
+//A transport failure is returned as error, the lines that were not sent are counted in FlushResult.Failed
+//and put back into the buffer, which stays marked so the next Flush retries them
+func (logFile *LFile) Flush() (FlushResult, error) {
+	//Take the buffered lines and the error state, so logging can continue while flushing
+	logFile.mu.Lock()
+	errorHappened := logFile.errorHappened
+	var records []Record
+	var size int
+	if errorHappened {
+		size = logFile.buffer.size
+		records = logFile.buffer.drain()
+		logFile.errorHappened = false
+		if logFile.registry != nil {
+			logFile.registry.account(-size)
+		}
+	}
+	logFile.mu.Unlock()
+
+	result := FlushResult{Bytes: size, Destination: logFile.sink.Destination()}
+
+	//Only flush if error has occurred
+	if !errorHappened {
+		Diagnostics.WithFields(
 			logrus.Fields{
 				"serviceName": logFile.serviceName,
 				"serviceInfo": logFile.serviceInfo,
 			}).Info("Buffer cleared without flushing to file")
+		return result, nil
 	}
 
+	result, err := logFile.send(logFile.sink, records, FlushReasonError, result)
+	if err != nil {
+		logFile.requeue(records[result.Lines:])
+	}
+	return result, err
 }
 
-//CreateLogBuffer creates an in-memory buffer to temporarily store logs
-func CreateLogBuffer(serviceName string, serviceInfo string, fluentPort int, fluentHost string) (LFile, *logrus.Entry) {
-	//Check if there is already an LFile with these credentials
-	if checkBufSlice(serviceName, serviceInfo) {
-		//if LFile already exists, return it
-		logrus.Warn("Buffer already exists, returning existing buffer")
-		var logFile, entry = GetLogBufferAndLogger(serviceName, serviceInfo)
-		if entry == nil {
-			logrus.Warn("Nil buffer")
-		}
-		return logFile, entry
+//requeue puts records that could not be sent back into the buffer and marks it again
+func (logFile *LFile) requeue(records []Record) {
+	logFile.mu.Lock()
+	before := logFile.buffer.size
+	logFile.buffer.requeue(records)
+	logFile.errorHappened = true
+	delta := logFile.buffer.size - before
+	registry := logFile.registry
+	if registry != nil {
+		registry.account(delta)
+	}
+	logFile.mu.Unlock()
+
+	if registry != nil && delta > 0 {
+		registry.reclaim()
+	}
+}
+
+//send hands records to sink and completes result, reason is attached to the batch
+func (logFile *LFile) send(sink Sink, records []Record, reason FlushReason, result FlushResult) (FlushResult, error) {
+	start := time.Now()
+	batch := logFile.batch(records, reason)
+
+	var sendErr error
+	sent, err := sink.Send(batch)
+	result.Lines = sent
+	if err != nil {
+		result.Failed = len(batch.Records) - sent
+		sendErr = fmt.Errorf("flushing %s to %s: %v", batch.Tag, result.Destination, err)
+	}
+	result.Duration = time.Since(start)
+
+	logFile.mu.Lock()
+	logFile.lastFlush = start
+	logFile.mu.Unlock()
+
+	//Calculate flush time
+	Diagnostics.WithFields(
+		logrus.Fields{
+			"serviceName": logFile.serviceName,
+			"serviceInfo": logFile.serviceInfo,
+			"reason":      reason,
+			"lines":       result.Lines,
+			"failed":      result.Failed,
+		}).Info("Flushing took: ", result.Duration)
+
+	return result, sendErr
+}
+
+//batch wraps records in a Batch for the sinks
+func (logFile *LFile) batch(records []Record, reason FlushReason) Batch {
+	return Batch{
+		//Tag for Loki, easily filterable in Grafana
+		Tag:         logFile.serviceName + "." + logFile.serviceInfo,
+		ServiceName: logFile.serviceName,
+		ServiceInfo: logFile.serviceInfo,
+		Reason:      reason,
+		Records:     records,
+	}
+}
+
+//CreateLogBuffer creates an in-memory buffer to temporarily store logs in the default registry
+//The buffer keeps the last DefaultMaxEntries entries and DefaultMaxBytes bytes unless other limits are given in opts
+func CreateLogBuffer(serviceName string, serviceInfo string, fluentPort int, fluentHost string, opts ...BufferOption) (*LFile, *logrus.Entry) {
+	return defaultRegistry.CreateLogBuffer(serviceName, serviceInfo, fluentPort, fluentHost, opts...)
+}
+
+//newLogBuffer creates an LFile and the logger writing into it
+func newLogBuffer(serviceName string, serviceInfo string, fluentPort int, fluentHost string, config bufferConfig) *LFile {
+	//Create LFile object
+	logFile := &LFile{
+		buffer:      newRingBuffer(config.maxEntries, config.maxBytes),
+		serviceName: serviceName,
+		serviceInfo: serviceInfo,
+		port:        fluentPort,
+		host:        fluentHost,
+		sink:        config.sink,
+		triggers:    config.triggers,
+		config:      config,
 	}
-	//If it's a new LFile, return it and append it in the slice
-	memLog := &bytes.Buffer{}
+	logFile.streamIdle = sync.NewCond(&logFile.mu)
+
+	//The logger prints to stdout, the buffer captures the entries through its hook
 	logger := logrus.New()
-	multiWriter := io.MultiWriter(os.Stdout, memLog)
 	logger.SetFormatter(&logrus.JSONFormatter{})
-	logger.SetOutput(multiWriter)
+	logger.SetOutput(os.Stdout)
+	logger.AddHook(logFile)
+	if config.splitLevels {
+		splitLevels(logger, config.consoleLevel, config.bufferLevel)
+	}
 
 	//Create logrus.Entry
-	entry := logrus.NewEntry(logger)
-	//Create LFile object
-	var logFile = LFile{memLog, serviceName, serviceInfo, false, fluentPort, fluentHost}
+	logFile.entry = logrus.NewEntry(logger)
 
-	if len(bufSlice) < MaxNumberOfBuffers {
-		//If there is room in the slice, append new LFile and buffer to slice
-		bufSlice = append(bufSlice, logFile)
-		entrySlice = append(entrySlice, entry)
-	} else {
-		//If there isn't room in the slice, make new slice without first element and append new LFile
-		bufSlice = append(bufSlice[1:], logFile)
-		entrySlice = append(entrySlice[1:], entry)
-	}
+	return logFile
+}
 
-	return logFile, entry
+//Logger returns the logger that writes into the buffer
+func (logFile *LFile) Logger() *logrus.Entry {
+	return logFile.entry
 }
 
-//initFluent func initializes the fluentd forwarder
-func initFluent(port int, host string) *fluent.Fluent {
-	logger, err := fluent.New(fluent.Config{FluentPort: port, FluentHost: host, MarshalAsJSON: true})
-	if err != nil {
-		fmt.Println(err)
+//ServiceName returns the service name the buffer was created with
+func (logFile *LFile) ServiceName() string {
+	return logFile.serviceName
+}
+
+//ServiceInfo returns the service info the buffer was created with
+func (logFile *LFile) ServiceInfo() string {
+	return logFile.serviceInfo
+}
+
+//MarkError marks the buffer so the next Flush sends its contents
+func (logFile *LFile) MarkError() {
+	logFile.mu.Lock()
+	logFile.errorHappened = true
+	logFile.mu.Unlock()
+}
+
+//SetTriggerPolicy replaces the policy that decides which entries mark the buffer
+func (logFile *LFile) SetTriggerPolicy(policy TriggerPolicy) {
+	logFile.mu.Lock()
+	logFile.triggers = policy
+	logFile.mu.Unlock()
+}
+
+//Clear drops the buffered entries and the error state without flushing
+func (logFile *LFile) Clear() {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	if logFile.registry != nil {
+		logFile.registry.account(-logFile.buffer.size)
 	}
-	return logger
+	logFile.buffer.drain()
+	logFile.errorHappened = false
+}
+
+//ErrorHappened reports whether an error was logged since the last flush
+func (logFile *LFile) ErrorHappened() bool {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	return logFile.errorHappened
 }
 
 //Error pushes the error onto the buffer and flushes the buffer to file
@@ -143,24 +469,25 @@ func Error(logger *logrus.Entry, msg string, err error, logFile *LFile, m map[st
 	}
 	logger.WithFields(fields).Error(msg, err)
 
-	tempBool := &logFile.errorHappened
-	*tempBool = true
+	logFile.MarkError()
 }
 
 /*
 	Fatal func pushes the error onto the buffer and flushes the buffer to file
 	Afterwards the Fatal function from logrus is called
 */
-func Fatal(logger *logrus.Entry, msg string, err error, logFile LFile, m map[string]interface{}) {
+func Fatal(logger *logrus.Entry, msg string, err error, logFile *LFile, m map[string]interface{}) {
 	fields := logrus.Fields{}
 	for key, value := range m {
 		fields[key] = value
 	}
 	logger.WithFields(fields).Error(msg, err)
 
-	logFile.errorHappened = true
+	logFile.MarkError()
 	//Flush to file
-	logFile.Flush()
+	if _, flushErr := logFile.Flush(); flushErr != nil {
+		Diagnostics.Error(flushErr)
+	}
 	logrus.Fatal(msg, err)
 }
 
@@ -168,38 +495,27 @@ func Fatal(logger *logrus.Entry, msg string, err error, logFile LFile, m map[str
 	Panic func pushes the error onto the buffer and flushes the buffer to file
 	Afterwards the Panic function from logrus is called
 */
-func Panic(logger *logrus.Entry, msg string, err error, logFile LFile, m map[string]interface{}) {
+func Panic(logger *logrus.Entry, msg string, err error, logFile *LFile, m map[string]interface{}) {
 	fields := logrus.Fields{}
 	for key, value := range m {
 		fields[key] = value
 	}
 	logger.WithFields(fields).Error(msg, err)
-	logFile.errorHappened = true
+	logFile.MarkError()
 	//Flush to file
-	logFile.Flush()
+	if _, flushErr := logFile.Flush(); flushErr != nil {
+		Diagnostics.Error(flushErr)
+	}
 	logrus.Panic(msg, err)
 }
 
 // GetLogBufferAndLogger function
-func GetLogBufferAndLogger(serviceName string, serviceInfo string) (LFile, *logrus.Entry) {
-	for i, f := range bufSlice {
-		if f.serviceName == serviceName && f.serviceInfo == serviceInfo {
-			return f, entrySlice[i]
-		}
-	}
-	return LFile{}, nil
-}
-
-func checkBufSlice(serviceName string, serviceInfo string) bool {
-	for _, f := range bufSlice {
-		if f.serviceName == serviceName && f.serviceInfo == serviceInfo {
-			return true
-		}
-	}
-	return false
+func GetLogBufferAndLogger(serviceName string, serviceInfo string) (*LFile, *logrus.Entry) {
+	return defaultRegistry.GetLogBufferAndLogger(serviceName, serviceInfo)
 }
 
 //SetMaxAmountOfBuffers func -> Default = 200
 func SetMaxAmountOfBuffers(amount int) {
 	MaxNumberOfBuffers = amount
+	defaultRegistry.SetMaxBuffers(amount)
 }
@@ -0,0 +1,109 @@
+//Package bmgrpc gives every gRPC call a request-scoped buffer of the log package
+//
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(bmgrpc.UnaryServerInterceptor(logFile)),
+//		grpc.StreamInterceptor(bmgrpc.StreamServerInterceptor(logFile)),
+//	)
+package bmgrpc
+
+import (
+	"context"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//DefaultFlushCodes are the status codes that flush the buffer of a call when no WithFlushCodes option is given
+var DefaultFlushCodes = []codes.Code{codes.Internal, codes.Unknown, codes.DataLoss}
+
+//Option configures the interceptors
+type Option func(*options)
+
+type options struct {
+	flushCodes map[codes.Code]bool
+}
+
+//WithFlushCodes flushes the buffer of a call when its handler returns one of flushCodes
+func WithFlushCodes(flushCodes ...codes.Code) Option {
+	return func(o *options) {
+		o.flushCodes = make(map[codes.Code]bool, len(flushCodes))
+		for _, code := range flushCodes {
+			o.flushCodes[code] = true
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	WithFlushCodes(DefaultFlushCodes...)(o)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+//UnaryServerInterceptor gives every unary call a child buffer of parent, carried in the call context
+//The buffer is flushed when the handler panics or returns one of the flush codes, and thrown away otherwise
+func UnaryServerInterceptor(parent *log.LFile, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, callLog := beginCall(ctx, parent, info.FullMethod)
+		defer func() {
+			o.finishCall(callLog, recover(), err)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+//StreamServerInterceptor gives every streaming call a child buffer of parent, carried in the stream context
+//The buffer is flushed when the handler panics or returns one of the flush codes, and thrown away otherwise
+func StreamServerInterceptor(parent *log.LFile, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, callLog := beginCall(stream.Context(), parent, info.FullMethod)
+		defer func() {
+			o.finishCall(callLog, recover(), err)
+		}()
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+//beginCall creates the buffer of a call, its entries carry the full method name and the peer address
+func beginCall(ctx context.Context, parent *log.LFile, fullMethod string) (context.Context, *log.LFile) {
+	fields := logrus.Fields{"grpc.method": fullMethod}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields["peer.address"] = p.Addr.String()
+	}
+	return parent.BeginRequest(ctx, fields)
+}
+
+//finishCall marks the buffer of a failed call and finishes it, a recovered panic is raised again afterwards
+func (o *options) finishCall(callLog *log.LFile, recovered interface{}, err error) {
+	if recovered != nil {
+		log.RecordPanic(callLog, recovered)
+	} else if code := status.Code(err); o.flushCodes[code] {
+		callLog.Logger().WithField("grpc.code", code.String()).WithError(err).Error("Call failed")
+		callLog.MarkError()
+	}
+
+	if _, flushErr := callLog.Finish(); flushErr != nil {
+		log.Diagnostics.Error(flushErr)
+	}
+	if recovered != nil {
+		panic(recovered)
+	}
+}
+
+//serverStream carries the context holding the buffer of the call
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
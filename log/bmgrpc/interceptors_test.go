@@ -0,0 +1,173 @@
+package bmgrpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	log.Diagnostics.SetOutput(ioutil.Discard)
+}
+
+const method = "/users.Users/Get"
+
+//newTestParent returns a buffer flushing into the returned sink, printing nothing
+func newTestParent() (*log.LFile, *log.MemorySink) {
+	sink := &log.MemorySink{}
+	parent, logger := log.NewRegistry(0).CreateLogBuffer("service", "grpc", 0, "", log.WithSink(sink))
+	logger.Logger.SetOutput(ioutil.Discard)
+	return parent, sink
+}
+
+//peerContext returns a context of a call from 10.0.0.1:5000
+func peerContext() context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}})
+}
+
+//handle logs in the buffer of the call and returns err
+func handle(err error) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		log.FromContext(ctx).Logger().Info("handling")
+		return "response", err
+	}
+}
+
+func TestUnaryServerInterceptorFlushCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		err     error
+		flushed bool
+	}{
+		{"ok", nil, nil, false},
+		{"not found", nil, status.Error(codes.NotFound, "no such user"), false},
+		{"internal", nil, status.Error(codes.Internal, "database down"), true},
+		{"plain error", nil, context.Canceled, true},
+		{"custom code", []Option{WithFlushCodes(codes.NotFound)}, status.Error(codes.NotFound, "no such user"), true},
+		{"custom codes replace the default", []Option{WithFlushCodes(codes.NotFound)}, status.Error(codes.Internal, "database down"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, sink := newTestParent()
+			interceptor := UnaryServerInterceptor(parent, test.opts...)
+
+			resp, err := interceptor(peerContext(), "request", &grpc.UnaryServerInfo{FullMethod: method}, handle(test.err))
+			if resp != "response" || err != test.err {
+				t.Errorf("returned %v, %v", resp, err)
+			}
+			records := sink.Records()
+			if flushed := len(records) > 0; flushed != test.flushed {
+				t.Fatalf("flushed %v, want %v", flushed, test.flushed)
+			}
+			if !test.flushed {
+				if parent.ErrorHappened() || len(parent.Records()) != 0 {
+					t.Errorf("discarded call left %v in the parent", parent.Stats())
+				}
+				return
+			}
+
+			if got, want := messages(records), []string{"handling", "Call failed"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("flushed %v, want %v", got, want)
+			}
+			for _, record := range records {
+				if record.Fields["grpc.method"] != method || record.Fields["peer.address"] != "10.0.0.1:5000" {
+					t.Errorf("record %q has fields %v", record.Message, record.Fields)
+				}
+			}
+			if got, want := records[1].Fields["grpc.code"], status.Code(test.err).String(); got != want {
+				t.Errorf("grpc.code %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorPanic(t *testing.T) {
+	parent, sink := newTestParent()
+	interceptor := UnaryServerInterceptor(parent)
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		interceptor(peerContext(), "request", &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			log.FromContext(ctx).Logger().Info("handling")
+			panic("boom")
+		})
+	}()
+	if recovered != "boom" {
+		t.Errorf("recovered %v, want the panic raised again", recovered)
+	}
+
+	records := sink.Records()
+	if got, want := messages(records), []string{"handling", "Panic recovered"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("flushed %v, want %v", got, want)
+	}
+	if got := records[1].Fields["panic"]; got != "boom" {
+		t.Errorf("panic field %v", got)
+	}
+	if got := records[1].Fields["grpc.method"]; got != method {
+		t.Errorf("grpc.method %v, want %v", got, method)
+	}
+}
+
+//testStream is a server stream that only has a context
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		flushed bool
+	}{
+		{"ok", nil, false},
+		{"unavailable", status.Error(codes.Unavailable, "try again"), false},
+		{"data loss", status.Error(codes.DataLoss, "truncated"), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, sink := newTestParent()
+			interceptor := StreamServerInterceptor(parent)
+
+			err := interceptor(nil, &testStream{ctx: peerContext()}, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, stream grpc.ServerStream) error {
+				log.FromContext(stream.Context()).Logger().Info("streaming")
+				return test.err
+			})
+			if err != test.err {
+				t.Errorf("returned %v, want %v", err, test.err)
+			}
+			records := sink.Records()
+			if flushed := len(records) > 0; flushed != test.flushed {
+				t.Fatalf("flushed %v, want %v", flushed, test.flushed)
+			}
+			for _, record := range records {
+				if record.Fields["grpc.method"] != method || record.Fields["peer.address"] != "10.0.0.1:5000" {
+					t.Errorf("record %q has fields %v", record.Message, record.Fields)
+				}
+			}
+		})
+	}
+}
+
+//messages returns the messages of records
+func messages(records []log.Record) []string {
+	result := make([]string, len(records))
+	for i, record := range records {
+		result[i] = record.Message
+	}
+	return result
+}
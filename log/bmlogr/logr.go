@@ -0,0 +1,138 @@
+//Package bmlogr feeds github.com/go-logr/logr logs into the buffers of the log package
+//
+//	logFile, _ := log.CreateLogBuffer("my-controller", "reconciler", 24224, "fluentd")
+//	logger := logr.New(bmlogr.NewLogSink(logFile, 1, nil))
+package bmlogr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"github.com/go-logr/logr"
+	"github.com/sirupsen/logrus"
+)
+
+//LogSink is a logr.LogSink capturing into an LFile and passing entries on to an inner sink
+type LogSink struct {
+	logFile   *log.LFile
+	inner     logr.LogSink
+	verbosity int
+	name      string
+	values    map[string]interface{}
+}
+
+//NewLogSink creates a sink capturing entries up to V(verbosity) into logFile
+//V(0) is captured at Info level, V(1) at Debug level and higher verbosity at Trace level
+//Entries are also passed on to inner if it is not nil and enabled for their level, e.g. inner can be funcr.New(...).GetSink()
+func NewLogSink(logFile *log.LFile, verbosity int, inner logr.LogSink) *LogSink {
+	return &LogSink{logFile: logFile, inner: inner, verbosity: verbosity}
+}
+
+//Init accounts for the extra stack frame between the logger and the inner sink
+//The inner sink was already initialized by the logger it was taken from
+func (s *LogSink) Init(info logr.RuntimeInfo) {
+	if inner, ok := s.inner.(logr.CallDepthLogSink); ok {
+		s.inner = inner.WithCallDepth(1)
+	}
+}
+
+//Enabled reports whether the buffer or the inner sink wants entries at level
+func (s *LogSink) Enabled(level int) bool {
+	return level <= s.verbosity || (s.inner != nil && s.inner.Enabled(level))
+}
+
+//Info captures a non-error entry
+func (s *LogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if level <= s.verbosity {
+		s.capture(logrusLevel(level), msg, nil, keysAndValues)
+	}
+	if s.inner != nil && s.inner.Enabled(level) {
+		s.inner.Info(level, msg, keysAndValues...)
+	}
+}
+
+//Error captures an error entry, which marks the buffer under the default trigger policy
+func (s *LogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.capture(logrus.ErrorLevel, msg, err, keysAndValues)
+	if s.inner != nil {
+		s.inner.Error(err, msg, keysAndValues...)
+	}
+}
+
+//WithValues returns a sink adding keysAndValues to every entry
+func (s *LogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	clone := *s
+	clone.values = make(map[string]interface{}, len(s.values)+len(keysAndValues)/2)
+	for key, value := range s.values {
+		clone.values[key] = value
+	}
+	addKeysAndValues(clone.values, keysAndValues)
+	if s.inner != nil {
+		clone.inner = s.inner.WithValues(keysAndValues...)
+	}
+	return &clone
+}
+
+//WithName returns a sink whose entries carry name, appended to the current name with a "/"
+//The inner sink is given name alone, it joins it to its own name itself
+func (s *LogSink) WithName(name string) logr.LogSink {
+	clone := *s
+	clone.name = name
+	if s.name != "" {
+		clone.name = s.name + "/" + name
+	}
+	if s.inner != nil {
+		clone.inner = s.inner.WithName(name)
+	}
+	return &clone
+}
+
+//capture stores one entry in the buffer
+func (s *LogSink) capture(level logrus.Level, msg string, err error, keysAndValues []interface{}) {
+	fields := make(map[string]interface{}, len(s.values)+len(keysAndValues)/2+2)
+	for key, value := range s.values {
+		fields[key] = value
+	}
+	addKeysAndValues(fields, keysAndValues)
+	if s.name != "" {
+		fields["logger"] = s.name
+	}
+	if err != nil {
+		fields[logrus.ErrorKey] = err
+	}
+
+	s.logFile.Capture(log.Record{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Fields:  fields,
+	})
+}
+
+//addKeysAndValues adds alternating keys and values to fields, a key without value gets "<no-value>"
+func addKeysAndValues(fields map[string]interface{}, keysAndValues []interface{}) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var value interface{} = "<no-value>"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fields[key] = value
+	}
+}
+
+//logrusLevel maps a logr verbosity onto a logrus level
+func logrusLevel(level int) logrus.Level {
+	switch {
+	case level <= 0:
+		return logrus.InfoLevel
+	case level == 1:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
@@ -0,0 +1,127 @@
+package bmlogr
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"github.com/go-logr/logr"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	log.Diagnostics.SetOutput(ioutil.Discard)
+}
+
+//innerSink is a logr.LogSink remembering the name and messages it was given, joining names like funcr does
+type innerSink struct {
+	name     string
+	level    int
+	messages *[]string
+}
+
+func (s *innerSink) Init(info logr.RuntimeInfo) {}
+
+func (s *innerSink) Enabled(level int) bool {
+	return level <= s.level
+}
+
+func (s *innerSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	*s.messages = append(*s.messages, s.name+":"+msg)
+}
+
+func (s *innerSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	*s.messages = append(*s.messages, s.name+":"+msg)
+}
+
+func (s *innerSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return s
+}
+
+func (s *innerSink) WithName(name string) logr.LogSink {
+	clone := *s
+	clone.name = strings.TrimPrefix(s.name+"/"+name, "/")
+	return &clone
+}
+
+//newTestLogger returns a logger capturing up to verbosity into a new buffer, passing V(0) on to the returned messages
+func newTestLogger(verbosity int) (logr.Logger, *log.LFile, *log.MemorySink, *[]string) {
+	sink := &log.MemorySink{}
+	logFile, _ := log.NewRegistry(0).CreateLogBuffer("service", "logr", 0, "", log.WithSink(sink))
+	var messages []string
+	return logr.New(NewLogSink(logFile, verbosity, &innerSink{messages: &messages})), logFile, sink, &messages
+}
+
+func TestLogSinkVerbosity(t *testing.T) {
+	logger, logFile, _, messages := newTestLogger(1)
+
+	logger.Info("info")
+	logger.V(1).Info("debug")
+	logger.V(2).Info("trace")
+
+	var levels []logrus.Level
+	for _, record := range logFile.Records() {
+		levels = append(levels, record.Level)
+	}
+	if want := []logrus.Level{logrus.InfoLevel, logrus.DebugLevel}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("captured levels %v, want %v", levels, want)
+	}
+	if want := []string{":info"}; !reflect.DeepEqual(*messages, want) {
+		t.Errorf("inner sink got %v, want %v", *messages, want)
+	}
+}
+
+func TestLogSinkWithValues(t *testing.T) {
+	logger, logFile, _, _ := newTestLogger(0)
+
+	request := logger.WithValues("request", 7, "user")
+	request.Info("handling", "step", "load")
+	logger.Info("plain")
+
+	records := logFile.Records()
+	want := map[string]interface{}{"request": 7, "user": "<no-value>", "step": "load"}
+	if len(records) != 2 || !reflect.DeepEqual(records[0].Fields, want) {
+		t.Errorf("captured %+v, want fields %v", records, want)
+	}
+	if len(records[1].Fields) != 0 {
+		t.Errorf("WithValues added %v to the parent logger", records[1].Fields)
+	}
+}
+
+func TestLogSinkWithName(t *testing.T) {
+	logger, logFile, _, messages := newTestLogger(0)
+
+	logger.WithName("a").WithName("b").Info("named")
+
+	records := logFile.Records()
+	if len(records) != 1 || records[0].Fields["logger"] != "a/b" {
+		t.Errorf("captured %+v, want logger a/b", records)
+	}
+	if want := []string{"a/b:named"}; !reflect.DeepEqual(*messages, want) {
+		t.Errorf("inner sink got %v, want %v", *messages, want)
+	}
+}
+
+func TestLogSinkErrorMarksBuffer(t *testing.T) {
+	logger, logFile, sink, _ := newTestLogger(0)
+
+	logger.Info("before")
+	if logFile.ErrorHappened() {
+		t.Fatal("an info entry marked the buffer")
+	}
+	logger.Error(errors.New("connection refused"), "reconcile failed")
+	if !logFile.ErrorHappened() {
+		t.Fatal("an error entry did not mark the buffer")
+	}
+
+	if _, err := logFile.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	records := sink.Records()
+	if len(records) != 2 || records[1].Level != logrus.ErrorLevel || records[1].Data()[logrus.ErrorKey] != "connection refused" {
+		t.Errorf("flushed %+v", records)
+	}
+}
@@ -0,0 +1,145 @@
+//Package bmzap feeds go.uber.org/zap logs into the buffers of the log package
+package bmzap
+
+import (
+	"runtime"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap/zapcore"
+)
+
+//Core is a zapcore.Core that captures entries into an LFile and tees them to an inner core for console output
+//The buffer is flushed to its sink as soon as an entry at or above the trigger level, or matching the TriggerPolicy
+//of the buffer, is written
+type Core struct {
+	logFile      *log.LFile
+	inner        zapcore.Core
+	captureLevel zapcore.LevelEnabler
+	triggerLevel zapcore.Level
+	fields       []zapcore.Field
+}
+
+//Option configures a Core
+type Option func(*Core)
+
+//WithCaptureLevel sets the entries that are captured into the buffer, by default zapcore.DebugLevel and above
+func WithCaptureLevel(level zapcore.LevelEnabler) Option {
+	return func(c *Core) {
+		c.captureLevel = level
+	}
+}
+
+//WithTriggerLevel sets the level from which an entry flushes the buffer, by default zapcore.ErrorLevel
+//It only applies to the entries of the core, the TriggerPolicy of the buffer is left as is
+func WithTriggerLevel(level zapcore.Level) Option {
+	return func(c *Core) {
+		c.triggerLevel = level
+	}
+}
+
+//NewCore creates a core capturing into logFile, inner may be nil to log to the buffer only
+//
+//	logFile, _ := log.CreateLogBuffer("my-service", "api", 24224, "fluentd")
+//	console := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.Lock(os.Stdout), zapcore.InfoLevel)
+//	logger := zap.New(bmzap.NewCore(logFile, console))
+func NewCore(logFile *log.LFile, inner zapcore.Core, opts ...Option) *Core {
+	core := &Core{
+		logFile:      logFile,
+		inner:        inner,
+		captureLevel: zapcore.DebugLevel,
+		triggerLevel: zapcore.ErrorLevel,
+	}
+	for _, opt := range opts {
+		opt(core)
+	}
+	if core.inner == nil {
+		core.inner = zapcore.NewNopCore()
+	}
+	return core
+}
+
+//Enabled reports whether the buffer or the inner core wants entries at level
+func (c *Core) Enabled(level zapcore.Level) bool {
+	return c.captureLevel.Enabled(level) || c.inner.Enabled(level)
+}
+
+//With returns a core adding fields to every entry
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	clone.inner = c.inner.With(fields)
+	return &clone
+}
+
+//Check adds the core if the entry is captured, and lets the inner core add itself
+func (c *Core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.captureLevel.Enabled(entry.Level) {
+		checked = checked.AddCore(entry, c)
+	}
+	return c.inner.Check(entry, checked)
+}
+
+//Write captures entry into the buffer and flushes the buffer if entry triggers it
+//DPanic, Panic and Fatal entries may end the goroutine or the process, so the buffer is flushed before zap does so
+func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	record := log.Record{
+		Time:    entry.Time,
+		Level:   logrusLevel(entry.Level),
+		Message: entry.Message,
+		Fields:  encoder.Fields,
+	}
+	if entry.Caller.Defined {
+		record.Caller = &runtime.Frame{
+			PC:       entry.Caller.PC,
+			Function: entry.Caller.Function,
+			File:     entry.Caller.File,
+			Line:     entry.Caller.Line,
+		}
+	}
+	triggered, sent := c.logFile.CaptureTrigger(record, entry.Level >= c.triggerLevel)
+
+	//A post-trigger window has sent the buffer already
+	if !sent && (triggered || entry.Level >= zapcore.DPanicLevel) {
+		if _, err := c.logFile.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Sync waits for the entries the buffer streams after a trigger and syncs the inner core
+func (c *Core) Sync() error {
+	c.logFile.WaitStreamed()
+	return c.inner.Sync()
+}
+
+//logrusLevel maps a zap level onto the matching logrus level
+func logrusLevel(level zapcore.Level) logrus.Level {
+	switch level {
+	case zapcore.DebugLevel:
+		return logrus.DebugLevel
+	case zapcore.InfoLevel:
+		return logrus.InfoLevel
+	case zapcore.WarnLevel:
+		return logrus.WarnLevel
+	case zapcore.PanicLevel:
+		return logrus.PanicLevel
+	case zapcore.FatalLevel:
+		return logrus.FatalLevel
+	case zapcore.ErrorLevel, zapcore.DPanicLevel:
+		return logrus.ErrorLevel
+	}
+	if level < zapcore.DebugLevel {
+		return logrus.TraceLevel
+	}
+	return logrus.ErrorLevel
+}
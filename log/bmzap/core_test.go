@@ -0,0 +1,104 @@
+package bmzap
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	log.Diagnostics.SetOutput(ioutil.Discard)
+}
+
+//newTestCore returns a core capturing into a new buffer flushing into the returned sink
+func newTestCore(t *testing.T, bufferOpts []log.BufferOption, opts ...Option) (zapcore.Core, *log.LFile, *log.MemorySink) {
+	sink := &log.MemorySink{}
+	logFile, _ := log.NewRegistry(0).CreateLogBuffer("service", "zap", 0, "", append(bufferOpts, log.WithSink(sink))...)
+	return NewCore(logFile, nil, opts...), logFile, sink
+}
+
+//write writes an entry through core the way a zap logger does
+func write(t *testing.T, core zapcore.Core, level zapcore.Level, message string, fields ...zapcore.Field) {
+	if err := core.Write(zapcore.Entry{Level: level, Message: message}, fields); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoreFlushesOnTrigger(t *testing.T) {
+	core, logFile, sink := newTestCore(t, nil)
+
+	write(t, core, zapcore.InfoLevel, "ok", zapcore.Field{Key: "user", Type: zapcore.StringType, String: "42"})
+	if len(sink.Batches()) != 0 {
+		t.Fatal("an info entry flushed the buffer")
+	}
+	write(t, core, zapcore.ErrorLevel, "boom")
+
+	batches := sink.Batches()
+	if len(batches) != 1 || batches[0].Reason != log.FlushReasonError {
+		t.Fatalf("sent %d batches, want one error batch", len(batches))
+	}
+	records := batches[0].Records
+	if len(records) != 2 || records[0].Fields["user"] != "42" || records[1].Message != "boom" {
+		t.Errorf("flushed %+v", records)
+	}
+	if logFile.ErrorHappened() {
+		t.Error("buffer is still marked after the flush")
+	}
+}
+
+func TestCoreTriggerLevel(t *testing.T) {
+	tests := []struct {
+		name         string
+		triggerLevel zapcore.Level
+		level        zapcore.Level
+		flushed      bool
+	}{
+		{"below the trigger level", zapcore.ErrorLevel, zapcore.WarnLevel, false},
+		{"lowered trigger level", zapcore.WarnLevel, zapcore.WarnLevel, true},
+		{"raised trigger level still flushes on the buffer policy", zapcore.FatalLevel, zapcore.ErrorLevel, true},
+		{"development panic", zapcore.FatalLevel, zapcore.DPanicLevel, true},
+		{"panic", zapcore.FatalLevel, zapcore.PanicLevel, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core, _, sink := newTestCore(t, nil, WithTriggerLevel(test.triggerLevel))
+			write(t, core, zapcore.InfoLevel, "before")
+			write(t, core, test.level, "entry")
+
+			if flushed := len(sink.Records()) == 2; flushed != test.flushed {
+				t.Errorf("flushed %v, want %v", flushed, test.flushed)
+			}
+		})
+	}
+}
+
+func TestCoreTriggerLevelLeavesBufferPolicy(t *testing.T) {
+	core, logFile, _ := newTestCore(t, nil, WithTriggerLevel(zapcore.WarnLevel))
+	logger := logFile.Logger()
+	logger.Logger.SetOutput(ioutil.Discard)
+
+	write(t, core, zapcore.InfoLevel, "zap")
+	logger.Warn("logrus")
+	if logFile.ErrorHappened() {
+		t.Error("the trigger level of the core applied to the logrus logger of the buffer")
+	}
+}
+
+func TestCorePostTriggerWindowFlushesOnce(t *testing.T) {
+	core, _, sink := newTestCore(t, []log.BufferOption{log.WithPostTriggerWindow(1, 0)})
+
+	write(t, core, zapcore.InfoLevel, "before")
+	write(t, core, zapcore.ErrorLevel, "boom")
+	write(t, core, zapcore.InfoLevel, "after")
+	core.Sync()
+
+	var reasons []log.FlushReason
+	for _, batch := range sink.Batches() {
+		reasons = append(reasons, batch.Reason)
+	}
+	if len(reasons) != 2 || reasons[0] != log.FlushReasonError || reasons[1] != log.FlushReasonPostTrigger {
+		t.Errorf("sent batches for %v, want one error and one post-trigger batch", reasons)
+	}
+}
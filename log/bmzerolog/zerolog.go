@@ -0,0 +1,128 @@
+//Package bmzerolog feeds github.com/rs/zerolog logs into the buffers of the log package
+//
+//A Writer captures complete events, including their fields:
+//
+//	logFile, _ := log.CreateLogBuffer("my-service", "api", 24224, "fluentd")
+//	logger := zerolog.New(zerolog.MultiLevelWriter(os.Stdout, bmzerolog.NewWriter(logFile))).With().Timestamp().Logger()
+//
+//A Hook only sees the level and message of an event, it is meant for loggers whose output cannot be redirected.
+//Use either of them on a logger, not both, or every event is buffered twice.
+package bmzerolog
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"github.com/rs/zerolog"
+	"github.com/sirupsen/logrus"
+)
+
+//Writer is a zerolog.LevelWriter parsing the JSON events of a logger into records of an LFile
+type Writer struct {
+	logFile *log.LFile
+}
+
+//NewWriter creates a writer capturing into logFile
+func NewWriter(logFile *log.LFile) *Writer {
+	return &Writer{logFile: logFile}
+}
+
+//Write captures one JSON encoded event
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+//WriteLevel captures one JSON encoded event logged at level
+func (w *Writer) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return 0, err
+	}
+
+	record := log.Record{Time: time.Now(), Fields: fields}
+	if value, ok := fields[zerolog.LevelFieldName].(string); ok {
+		if parsed, err := zerolog.ParseLevel(value); err == nil {
+			level = parsed
+		}
+		delete(fields, zerolog.LevelFieldName)
+	}
+	record.Level = logrusLevel(level)
+	if value, ok := fields[zerolog.MessageFieldName].(string); ok {
+		record.Message = value
+		delete(fields, zerolog.MessageFieldName)
+	}
+	if value, ok := fields[zerolog.TimestampFieldName]; ok {
+		if parsed, ok := parseTime(value); ok {
+			record.Time = parsed
+			delete(fields, zerolog.TimestampFieldName)
+		}
+	}
+
+	w.logFile.Capture(record)
+	return len(p), nil
+}
+
+//Hook is a zerolog.Hook capturing the level and message of every event into an LFile
+type Hook struct {
+	logFile *log.LFile
+}
+
+//NewHook creates a hook capturing into logFile
+func NewHook(logFile *log.LFile) Hook {
+	return Hook{logFile: logFile}
+}
+
+//Run captures the level and message of an event
+func (h Hook) Run(e *zerolog.Event, level zerolog.Level, message string) {
+	h.logFile.Capture(log.Record{
+		Time:    time.Now(),
+		Level:   logrusLevel(level),
+		Message: message,
+	})
+}
+
+//parseTime reads a timestamp written in zerolog.TimeFieldFormat
+func parseTime(value interface{}) (time.Time, bool) {
+	switch value := value.(type) {
+	case string:
+		if parsed, err := time.Parse(zerolog.TimeFieldFormat, value); err == nil {
+			return parsed, true
+		}
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return parsed, true
+		}
+	case float64:
+		switch zerolog.TimeFieldFormat {
+		case zerolog.TimeFormatUnix:
+			seconds := int64(value)
+			return time.Unix(seconds, int64((value-float64(seconds))*1e9)), true
+		case zerolog.TimeFormatUnixMs:
+			return time.Unix(0, int64(value)*int64(time.Millisecond)), true
+		case zerolog.TimeFormatUnixMicro:
+			return time.Unix(0, int64(value)*int64(time.Microsecond)), true
+		case zerolog.TimeFormatUnixNano:
+			return time.Unix(0, int64(value)), true
+		}
+	}
+	return time.Time{}, false
+}
+
+//logrusLevel maps a zerolog level onto the matching logrus level, events without level are Info
+func logrusLevel(level zerolog.Level) logrus.Level {
+	switch level {
+	case zerolog.TraceLevel:
+		return logrus.TraceLevel
+	case zerolog.DebugLevel:
+		return logrus.DebugLevel
+	case zerolog.WarnLevel:
+		return logrus.WarnLevel
+	case zerolog.ErrorLevel:
+		return logrus.ErrorLevel
+	case zerolog.FatalLevel:
+		return logrus.FatalLevel
+	case zerolog.PanicLevel:
+		return logrus.PanicLevel
+	}
+	return logrus.InfoLevel
+}
@@ -0,0 +1,130 @@
+package bmzerolog
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/BenjaminVanIseghem/be-mobile-logging/log"
+	"github.com/rs/zerolog"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	log.Diagnostics.SetOutput(ioutil.Discard)
+}
+
+func newTestBuffer() *log.LFile {
+	logFile, _ := log.NewRegistry(0).CreateLogBuffer("service", "zerolog", 0, "", log.WithSink(&log.MemorySink{}))
+	return logFile
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     func(logger zerolog.Logger)
+		level   logrus.Level
+		message string
+		fields  map[string]interface{}
+	}{
+		{"info", func(l zerolog.Logger) { l.Info().Str("user", "42").Msg("loaded") }, logrus.InfoLevel, "loaded", map[string]interface{}{"user": "42"}},
+		{"debug", func(l zerolog.Logger) { l.Debug().Int("attempt", 2).Msg("retrying") }, logrus.DebugLevel, "retrying", map[string]interface{}{"attempt": float64(2)}},
+		{"error", func(l zerolog.Logger) { l.Error().Msg("failed") }, logrus.ErrorLevel, "failed", map[string]interface{}{}},
+		{"without level", func(l zerolog.Logger) { l.Log().Msg("plain") }, logrus.InfoLevel, "plain", map[string]interface{}{}},
+		{"without message", func(l zerolog.Logger) { l.Warn().Bool("slow", true).Send() }, logrus.WarnLevel, "", map[string]interface{}{"slow": true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logFile := newTestBuffer()
+			test.log(zerolog.New(NewWriter(logFile)))
+
+			records := logFile.Records()
+			if len(records) != 1 {
+				t.Fatalf("captured %d records, want 1", len(records))
+			}
+			record := records[0]
+			if record.Level != test.level || record.Message != test.message {
+				t.Errorf("captured %v %q, want %v %q", record.Level, record.Message, test.level, test.message)
+			}
+			if len(record.Fields) != len(test.fields) {
+				t.Errorf("captured fields %v, want %v", record.Fields, test.fields)
+			}
+			for key, value := range test.fields {
+				if record.Fields[key] != value {
+					t.Errorf("captured %s=%v, want %v", key, record.Fields[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterTimestamp(t *testing.T) {
+	logged := time.Date(2019, 6, 1, 12, 0, 0, 250*int(time.Millisecond), time.UTC)
+	defer func(format string, now func() time.Time) {
+		zerolog.TimeFieldFormat = format
+		zerolog.TimestampFunc = now
+	}(zerolog.TimeFieldFormat, zerolog.TimestampFunc)
+	zerolog.TimestampFunc = func() time.Time { return logged }
+
+	tests := []struct {
+		format string
+		want   time.Time
+	}{
+		{time.RFC3339, logged.Truncate(time.Second)},
+		{time.RFC3339Nano, logged},
+		{zerolog.TimeFormatUnix, logged.Truncate(time.Second)},
+		{zerolog.TimeFormatUnixMs, logged},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			zerolog.TimeFieldFormat = test.format
+			logFile := newTestBuffer()
+			logger := zerolog.New(NewWriter(logFile)).With().Timestamp().Logger()
+			logger.Info().Msg("stamped")
+
+			record := logFile.Records()[0]
+			if !record.Time.Equal(test.want) {
+				t.Errorf("captured time %v, want %v", record.Time, test.want)
+			}
+			if _, ok := record.Fields[zerolog.TimestampFieldName]; ok {
+				t.Error("timestamp kept as a field")
+			}
+		})
+	}
+}
+
+func TestWriterErrorMarksBuffer(t *testing.T) {
+	logFile := newTestBuffer()
+	logger := zerolog.New(NewWriter(logFile))
+
+	logger.Warn().Msg("slow")
+	if logFile.ErrorHappened() {
+		t.Fatal("a warning marked the buffer")
+	}
+	logger.Error().Msg("failed")
+	if !logFile.ErrorHappened() {
+		t.Error("an error did not mark the buffer")
+	}
+}
+
+func TestHook(t *testing.T) {
+	logFile := newTestBuffer()
+	logger := zerolog.New(ioutil.Discard).Hook(NewHook(logFile))
+
+	logger.Info().Str("user", "42").Msg("loaded")
+	if logFile.ErrorHappened() {
+		t.Fatal("an info event marked the buffer")
+	}
+	logger.Error().Msg("failed")
+
+	records := logFile.Records()
+	if len(records) != 2 || records[0].Message != "loaded" || records[1].Level != logrus.ErrorLevel {
+		t.Errorf("captured %+v", records)
+	}
+	if len(records[0].Fields) != 0 {
+		t.Errorf("hook captured fields %v, it only sees level and message", records[0].Fields)
+	}
+	if !logFile.ErrorHappened() {
+		t.Error("an error event did not mark the buffer")
+	}
+}
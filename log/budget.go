@@ -0,0 +1,136 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//BudgetPolicy decides which buffer loses its oldest entries when the memory budget of a registry is exceeded
+type BudgetPolicy int
+
+const (
+	//EvictLargest takes entries from the buffer holding the most bytes
+	EvictLargest BudgetPolicy = iota
+	//EvictLeastRecentlyUsed takes entries from the buffer that was written to the longest time ago
+	EvictLeastRecentlyUsed
+)
+
+//SetMemoryBudget limits the total size of all buffers in the registry to bytes, 0 disables the limit
+//When the total goes over the budget, the oldest entries of the buffers chosen by policy are dropped
+func (r *Registry) SetMemoryBudget(bytes int64, policy BudgetPolicy) {
+	r.mu.Lock()
+	r.budgetPolicy = policy
+	r.mu.Unlock()
+
+	atomic.StoreInt64(&r.budget, bytes)
+	r.enforceBudget()
+}
+
+//MemoryUsage returns the total size in bytes of all buffers in the registry
+func (r *Registry) MemoryUsage() int64 {
+	return atomic.LoadInt64(&r.used)
+}
+
+//SetMemoryBudget limits the total size of the buffers in the default registry
+func SetMemoryBudget(bytes int64, policy BudgetPolicy) {
+	defaultRegistry.SetMemoryBudget(bytes, policy)
+}
+
+//account adds delta bytes of a buffer to the usage of the registry
+//The buffer must be locked and still belong to the registry, so no change is counted for a buffer the registry dropped.
+//Call reclaim once the buffer is unlocked.
+func (r *Registry) account(delta int) {
+	atomic.AddInt64(&r.used, int64(delta))
+}
+
+//reclaim drops entries if the registry exceeds its budget, no buffer may be locked by the caller
+func (r *Registry) reclaim() {
+	if budget := atomic.LoadInt64(&r.budget); budget > 0 && atomic.LoadInt64(&r.used) > budget {
+		r.enforceBudget()
+	}
+}
+
+//enforceBudget drops the oldest entries of buffers until the registry is within its budget
+//Entries are taken from one victim at a time, until the budget is met or the victim is no longer the first pick
+func (r *Registry) enforceBudget() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buffers []*LFile
+	for {
+		budget := atomic.LoadInt64(&r.budget)
+		excess := atomic.LoadInt64(&r.used) - budget
+		if budget <= 0 || excess <= 0 {
+			return
+		}
+		if buffers == nil {
+			buffers = r.allLocked()
+		}
+		victim, floor := r.pickVictim(buffers)
+		if victim == nil {
+			return
+		}
+
+		victim.mu.Lock()
+		//A finishing child is detached before it is untracked, its entries no longer count
+		if victim.registry != r {
+			victim.mu.Unlock()
+			buffers = without(buffers, victim)
+			continue
+		}
+		//Always take one entry, so buffers of equal size do not stall the loop
+		freed := victim.buffer.evictOldest()
+		for int64(freed) < excess && victim.buffer.size > floor {
+			freed += victim.buffer.evictOldest()
+		}
+		r.account(-freed)
+		victim.mu.Unlock()
+	}
+}
+
+//without returns buffers without logFile
+func without(buffers []*LFile, logFile *LFile) []*LFile {
+	kept := buffers[:0]
+	for _, buffer := range buffers {
+		if buffer != logFile {
+			kept = append(kept, buffer)
+		}
+	}
+	return kept
+}
+
+//pickVictim returns the non-empty buffer to evict from according to the budget policy, r.mu must be held
+//floor is the size the victim can shrink to before another buffer would be picked instead
+func (r *Registry) pickVictim(buffers []*LFile) (victim *LFile, floor int) {
+	var victimSize int
+	var victimLastWrite time.Time
+	for _, logFile := range buffers {
+		logFile.mu.Lock()
+		size, lastWrite := logFile.buffer.size, logFile.lastWrite
+		logFile.mu.Unlock()
+		if size == 0 {
+			continue
+		}
+
+		better := victim == nil
+		if !better && r.budgetPolicy == EvictLeastRecentlyUsed {
+			better = lastWrite.Before(victimLastWrite)
+		} else if !better {
+			better = size > victimSize
+		}
+		if better {
+			//The previous victim is now the runner-up
+			if victim != nil && victimSize > floor {
+				floor = victimSize
+			}
+			victim, victimSize, victimLastWrite = logFile, size, lastWrite
+		} else if size > floor {
+			floor = size
+		}
+	}
+	//The least recently used buffer stays the pick until it is empty
+	if r.budgetPolicy == EvictLeastRecentlyUsed {
+		floor = 0
+	}
+	return victim, floor
+}
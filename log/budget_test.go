@@ -0,0 +1,133 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+//budgetEntry is the size of the records captureN captures
+const budgetEntry = recordOverhead + 36
+
+//captureN captures n info records of budgetEntry bytes into logFile
+func captureN(logFile *LFile, n int) {
+	for i := 0; i < n; i++ {
+		logFile.Capture(Record{Level: logrus.InfoLevel, Message: strings.Repeat("x", 36)})
+	}
+}
+
+//bufferSizes returns the size of every buffer
+func bufferSizes(buffers ...*LFile) []int {
+	sizes := make([]int, len(buffers))
+	for i, logFile := range buffers {
+		sizes[i] = logFile.Stats().Bytes
+	}
+	return sizes
+}
+
+//checkUsage fails if the usage of r does not add up to the sizes of its buffers or exceeds the budget
+func checkUsage(t *testing.T, r *Registry, budget int64, buffers ...*LFile) {
+	t.Helper()
+	var total int64
+	for _, size := range bufferSizes(buffers...) {
+		total += int64(size)
+	}
+	if r.MemoryUsage() != total {
+		t.Errorf("MemoryUsage is %d, the buffers hold %d", r.MemoryUsage(), total)
+	}
+	if budget > 0 && total > budget {
+		t.Errorf("buffers hold %d bytes, over the budget of %d", total, budget)
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy BudgetPolicy
+		//captures holds the amount of records captured into buffer a, b and c, in that order
+		captures [3]int
+		budget   int64
+		want     [3]int
+	}{
+		{"within budget", EvictLargest, [3]int{2, 2, 2}, 6 * budgetEntry, [3]int{2, 2, 2}},
+		{"largest shrinks to the next largest", EvictLargest, [3]int{10, 2, 2}, 8 * budgetEntry, [3]int{4, 2, 2}},
+		{"largest buffers shrink evenly", EvictLargest, [3]int{10, 8, 2}, 10 * budgetEntry, [3]int{4, 4, 2}},
+		{"least recently used is emptied first", EvictLeastRecentlyUsed, [3]int{6, 4, 2}, 8 * budgetEntry, [3]int{2, 4, 2}},
+		{"least recently used spills over", EvictLeastRecentlyUsed, [3]int{4, 4, 4}, 6 * budgetEntry, [3]int{0, 2, 4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewRegistry(0)
+			var buffers []*LFile
+			for _, info := range []string{"a", "b", "c"} {
+				logFile, _ := r.CreateLogBuffer("service", info, 0, "", WithSink(&MemorySink{}), WithMaxEntries(0), WithMaxBytes(0))
+				buffers = append(buffers, logFile)
+			}
+			for i, n := range test.captures {
+				captureN(buffers[i], n)
+			}
+
+			r.SetMemoryBudget(test.budget, test.policy)
+			for i, want := range test.want {
+				if got := buffers[i].Stats().Entries; got != want {
+					t.Errorf("buffer %d holds %d entries, want %d", i, got, want)
+				}
+			}
+			checkUsage(t, r, test.budget, buffers...)
+		})
+	}
+}
+
+func TestMemoryBudgetWhileLogging(t *testing.T) {
+	const budget = 20 * budgetEntry
+	r := NewRegistry(0)
+	r.SetMemoryBudget(budget, EvictLargest)
+
+	parent, _ := r.CreateLogBuffer("service", "a", 0, "", WithSink(&MemorySink{}), WithMaxEntries(0), WithMaxBytes(0))
+	other, _ := r.CreateLogBuffer("service", "b", 0, "", WithSink(&MemorySink{}), WithMaxEntries(0), WithMaxBytes(0))
+	child := parent.NewChild(nil)
+	for i := 0; i < 50; i++ {
+		captureN(parent, 1)
+		captureN(other, 2)
+		captureN(child, 3)
+		checkUsage(t, r, budget, parent, other, child)
+	}
+
+	//Clearing and finishing give the memory back
+	other.Clear()
+	child.Finish()
+	checkUsage(t, r, budget, parent)
+}
+
+func TestMemoryUsageUnderBufferChurn(t *testing.T) {
+	r := NewRegistry(2)
+	r.SetMemoryBudget(64*budgetEntry, EvictLargest)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			var previous *LFile
+			for i := 0; i < 200; i++ {
+				//Registering a buffer drops the oldest one while the others keep logging into theirs
+				logFile, _ := r.CreateLogBuffer("service", fmt.Sprint(worker, "-", i), 0, "", WithSink(&MemorySink{}))
+				captureN(logFile, 2)
+				if previous != nil {
+					captureN(previous, 2)
+					previous.Clear()
+				}
+				child := logFile.NewChild(nil)
+				captureN(child, 2)
+				child.Finish()
+				previous = logFile
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	checkUsage(t, r, 64*budgetEntry, r.all()...)
+}
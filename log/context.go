@@ -0,0 +1,83 @@
+package log
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+//contextKey is the key an LFile is stored under in a context.Context
+type contextKey struct{}
+
+//NewContext returns a copy of ctx carrying logFile
+func NewContext(ctx context.Context, logFile *LFile) context.Context {
+	return context.WithValue(ctx, contextKey{}, logFile)
+}
+
+//FromContext returns the LFile carried by ctx, or nil if there is none
+func FromContext(ctx context.Context) *LFile {
+	logFile, _ := ctx.Value(contextKey{}).(*LFile)
+	return logFile
+}
+
+//NewChild creates a buffer for one unit of work, e.g. a request, with the settings and current trigger policy of logFile
+//Stateful triggers, like RateTrigger, start counting anew in the child.
+//Its logger adds fields to every entry. opts override the settings taken from logFile.
+//The child is not registered under a name, but counts towards the memory budget of the registry of logFile
+//until Finish is called
+func (logFile *LFile) NewChild(fields logrus.Fields, opts ...BufferOption) *LFile {
+	logFile.mu.Lock()
+	config := logFile.config
+	config.triggers = logFile.triggers.forChild()
+	registry := logFile.registry
+	logFile.mu.Unlock()
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+	child := newLogBuffer(logFile.serviceName, logFile.serviceInfo, logFile.port, logFile.host, config)
+	//With split levels the logger level follows from the console and buffer levels of config
+	if !config.splitLevels {
+		child.entry.Logger.SetLevel(logFile.level())
+	}
+	child.entry = child.entry.WithFields(fields)
+
+	if registry != nil {
+		registry.track(child)
+	}
+	return child
+}
+
+//BeginRequest creates a child buffer of logFile for one request and returns a context carrying it
+//The request must end with a call to Finish on the child
+//
+//	ctx, requestLog := logFile.BeginRequest(r.Context(), logrus.Fields{"path": r.URL.Path})
+//	defer requestLog.Finish()
+func (logFile *LFile) BeginRequest(ctx context.Context, fields logrus.Fields) (context.Context, *LFile) {
+	child := logFile.NewChild(fields)
+	return NewContext(ctx, child), child
+}
+
+//Finish ends the unit of work of a child buffer
+//The buffer is flushed if an error happened and thrown away otherwise, so a failing request only ships its own entries
+func (logFile *LFile) Finish() (FlushResult, error) {
+	var result FlushResult
+	var err error
+	if logFile.ErrorHappened() {
+		result, err = logFile.Flush()
+	}
+	logFile.Clear()
+
+	logFile.mu.Lock()
+	registry := logFile.registry
+	logFile.registry = nil
+	//Entries logged since Clear stay with the buffer but no longer count towards the budget
+	if registry != nil {
+		registry.account(-logFile.buffer.size)
+	}
+	logFile.mu.Unlock()
+	if registry != nil {
+		registry.untrack(logFile)
+	}
+	return result, err
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewChildLevel(t *testing.T) {
+	tests := []struct {
+		name        string
+		parentOpts  []BufferOption
+		parentLevel logrus.Level
+		childOpts   []BufferOption
+		captured    bool
+	}{
+		{"inherits the parent level", nil, logrus.DebugLevel, nil, true},
+		{"inherits the default level", nil, logrus.InfoLevel, nil, false},
+		{"buffer level of the child", nil, logrus.InfoLevel, []BufferOption{WithBufferLevel(logrus.DebugLevel)}, true},
+		{"buffer level of the parent", []BufferOption{WithBufferLevel(logrus.DebugLevel)}, 0, nil, true},
+		{"child overrides the buffer level of the parent", []BufferOption{WithBufferLevel(logrus.DebugLevel)}, 0, []BufferOption{WithBufferLevel(logrus.InfoLevel)}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, logger, _ := newTestBuffer(t, test.parentOpts...)
+			if test.parentLevel != 0 {
+				logger.Logger.SetLevel(test.parentLevel)
+			}
+			child := parent.NewChild(nil, test.childOpts...)
+			defer child.Finish()
+			child.Logger().Logger.SetOutput(ioutil.Discard)
+
+			child.Logger().Debug("x")
+			if captured := len(child.Records()) == 1; captured != test.captured {
+				t.Errorf("captured %v, want %v", captured, test.captured)
+			}
+		})
+	}
+}
+
+func TestBeginRequestFlushesOnlyFailedRequests(t *testing.T) {
+	parent, _, sink := newTestBuffer(t)
+
+	for _, failed := range []bool{false, true} {
+		ctx, requestLog := parent.BeginRequest(context.Background(), logrus.Fields{"failed": failed})
+		if FromContext(ctx) != requestLog {
+			t.Fatal("context does not carry the request buffer")
+		}
+		requestLog.Logger().Logger.SetOutput(ioutil.Discard)
+		FromContext(ctx).Logger().Info("handling")
+		if failed {
+			FromContext(ctx).Logger().Error("failed")
+		}
+		requestLog.Finish()
+	}
+
+	records := sink.Records()
+	if len(records) != 2 || records[0].Fields["failed"] != true {
+		t.Errorf("flushed %+v, want the two entries of the failed request", records)
+	}
+	if parent.registry.MemoryUsage() != 0 {
+		t.Errorf("registry accounts %d bytes after every request finished", parent.registry.MemoryUsage())
+	}
+}
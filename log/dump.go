@@ -0,0 +1,88 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+//DumpOptions configures a dump of buffers
+type DumpOptions struct {
+	//Clear drops the dumped entries from the buffers, by default they stay buffered
+	Clear bool
+	//Sink receives the dumped records instead of the sink of each buffer, e.g. NewWriterSink(os.Stderr)
+	Sink Sink
+}
+
+//Dump sends the buffered records to the sink of the buffer, or to opts.Sink, tagged with FlushReasonDump
+//The error state of the buffer is left untouched
+func (logFile *LFile) Dump(opts DumpOptions) (FlushResult, error) {
+	logFile.mu.Lock()
+	size := logFile.buffer.size
+	var records []Record
+	if opts.Clear {
+		records = logFile.buffer.drain()
+	} else {
+		records = logFile.buffer.snapshot()
+	}
+	if opts.Clear && logFile.registry != nil {
+		logFile.registry.account(-size)
+	}
+	logFile.mu.Unlock()
+
+	sink := opts.Sink
+	if sink == nil {
+		sink = logFile.sink
+	}
+	result := FlushResult{Bytes: size, Destination: sink.Destination()}
+	if len(records) == 0 {
+		return result, nil
+	}
+	return logFile.send(sink, records, FlushReasonDump, result)
+}
+
+//Dump dumps every buffer of the registry, see LFile.Dump
+//It returns the first error, after trying every buffer
+func (r *Registry) Dump(opts DumpOptions) error {
+	var firstErr error
+	for _, logFile := range r.all() {
+		if _, err := logFile.Dump(opts); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+//NotifyDump dumps every buffer of the registry whenever the process receives one of signals, e.g. syscall.SIGUSR1
+//Call the returned function to stop listening. Note that handling SIGQUIT replaces the goroutine dump Go does by default.
+func (r *Registry) NotifyDump(opts DumpOptions, signals ...os.Signal) (stop func()) {
+	received := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(received, signals...)
+
+	go func() {
+		for {
+			select {
+			case <-received:
+				if err := r.Dump(opts); err != nil {
+					Diagnostics.Error("Dumping buffers on signal: ", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(done)
+		})
+	}
+}
+
+//NotifyDump dumps every buffer of the default registry on signals, see Registry.NotifyDump
+func NotifyDump(opts DumpOptions, signals ...os.Signal) (stop func()) {
+	return defaultRegistry.NotifyDump(opts, signals...)
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//errorHook passes the messages of the errors logged to a channel
+type errorHook chan string
+
+func (h errorHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.ErrorLevel}
+}
+
+func (h errorHook) Fire(entry *logrus.Entry) error {
+	h <- entry.Message
+	return nil
+}
+
+func TestNotifyDumpReportsErrors(t *testing.T) {
+	hook := make(errorHook, 1)
+	hooks := Diagnostics.ReplaceHooks(logrus.LevelHooks{})
+	defer Diagnostics.ReplaceHooks(hooks)
+	Diagnostics.AddHook(hook)
+
+	logFile, logger, _ := newTestBuffer(t)
+	logger.Info("a")
+	stop := logFile.registry.NotifyDump(DumpOptions{Sink: &partialSink{}}, syscall.SIGUSR1)
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-hook:
+		if !strings.Contains(message, "connection reset") {
+			t.Errorf("reported %q, want the sink error", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed dump was not reported")
+	}
+}
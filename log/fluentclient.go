@@ -0,0 +1,151 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fluent/fluent-logger-golang/fluent"
+)
+
+var (
+	//FluentMinBackoff is the time a fluent client waits before reconnecting after its first failure
+	FluentMinBackoff = 500 * time.Millisecond
+	//FluentMaxBackoff caps the time a fluent client waits before reconnecting
+	FluentMaxBackoff = time.Minute
+	//FluentTimeout bounds connecting to fluentd
+	FluentTimeout = 3 * time.Second
+	//FluentWriteTimeout bounds sending one record to fluentd
+	//Records can be sent inside a logging call while the client is locked for every buffer, a fluentd that stalls
+	//must not block them forever
+	FluentWriteTimeout = 10 * time.Second
+
+	//errFluentBackoff is returned while a fluent client waits to reconnect
+	errFluentBackoff = errors.New("fluentd unavailable, waiting to reconnect")
+)
+
+//fluentClient is a lazily connected fluentd forwarder shared by every buffer sending to the same host and port
+//After a failure the connection is dropped and no new attempt is made until the backoff has passed
+type fluentClient struct {
+	host string
+	port int
+
+	mu       sync.Mutex
+	logger   *fluent.Fluent
+	failures int
+	retryAt  time.Time
+}
+
+func newFluentClient(host string, port int) *fluentClient {
+	return &fluentClient{host: host, port: port}
+}
+
+//address returns host:port of the fluentd the client sends to
+func (c *fluentClient) address() string {
+	return c.host + ":" + strconv.Itoa(c.port)
+}
+
+//post sends one record to fluentd with its own time, connecting first if needed
+func (c *fluentClient) post(tag string, tm time.Time, record map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.logger == nil {
+		if time.Now().Before(c.retryAt) {
+			return errFluentBackoff
+		}
+		//MaxRetry is kept at 1, the client does its own backoff instead of blocking the caller
+		//Records are sent as msgpack with sub-second event times, so lines of one flush keep their order in Loki
+		logger, err := fluent.New(fluent.Config{
+			FluentPort:         c.port,
+			FluentHost:         c.host,
+			SubSecondPrecision: true,
+			MaxRetry:           1,
+			Timeout:            FluentTimeout,
+			WriteTimeout:       FluentWriteTimeout,
+		})
+		if err != nil {
+			c.fail()
+			return fmt.Errorf("connecting to fluentd at %s: %v", c.address(), err)
+		}
+		c.logger = logger
+	}
+
+	if err := c.logger.PostWithTime(tag, tm, fluentRecord(record)); err != nil {
+		c.fail()
+		return err
+	}
+	c.failures = 0
+	return nil
+}
+
+//fail drops the connection and schedules the next attempt, c.mu must be held
+func (c *fluentClient) fail() {
+	if c.logger != nil {
+		c.logger.Close()
+		c.logger = nil
+	}
+	backoff := FluentMinBackoff << uint(c.failures)
+	if backoff <= 0 || backoff > FluentMaxBackoff {
+		backoff = FluentMaxBackoff
+	} else {
+		c.failures++
+	}
+	c.retryAt = time.Now().Add(backoff)
+}
+
+//Close closes the connection, a later post connects again
+func (c *fluentClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.logger == nil {
+		return nil
+	}
+	err := c.logger.Close()
+	c.logger = nil
+	return err
+}
+
+//fluentRecord returns a copy of record that msgpack can encode
+//Values msgpack has no encoding for, like structs and times, are converted as encoding/json would
+func fluentRecord(record map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(record))
+	for key, value := range record {
+		converted[key] = fluentValue(value)
+	}
+	return converted
+}
+
+//fluentValue converts value into a value msgpack can encode
+func fluentValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case nil, string, []byte, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return value
+	case map[string]interface{}:
+		return fluentRecord(value)
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, element := range value {
+			converted[i] = fluentValue(element)
+		}
+		return converted
+	}
+
+	//JSON only yields maps, slices, strings, numbers, booleans and nil
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return fmt.Sprint(value)
+	}
+	return decoded
+}
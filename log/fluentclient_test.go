@@ -0,0 +1,51 @@
+package log
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFluentClientWriteTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { FluentWriteTimeout = timeout }(FluentWriteTimeout)
+	FluentWriteTimeout = 100 * time.Millisecond
+
+	//A fluentd that accepts connections but never reads from them
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	stalled := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			stalled <- conn
+		}
+	}()
+	defer func() {
+		select {
+		case conn := <-stalled:
+			conn.Close()
+		default:
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+	client := newFluentClient(address.IP.String(), address.Port)
+	defer client.Close()
+
+	//Large enough to fill the socket buffers of both ends
+	record := map[string]interface{}{"msg": strings.Repeat("x", 64<<20)}
+	done := make(chan error, 1)
+	go func() { done <- client.post("service.test", time.Now(), record) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("post to a stalled fluentd succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("post to a stalled fluentd did not time out")
+	}
+}
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+//partialSink delivers the first n records of every batch and then fails
+type partialSink struct {
+	MemorySink
+	n int
+}
+
+func (s *partialSink) Send(batch Batch) (int, error) {
+	if len(batch.Records) <= s.n {
+		return s.MemorySink.Send(batch)
+	}
+	batch.Records = batch.Records[:s.n]
+	s.MemorySink.Send(batch)
+	return s.n, errors.New("connection reset")
+}
+
+func TestFlushRequeuesUnsentRecords(t *testing.T) {
+	sink := &partialSink{n: 1}
+	logFile, logger, _ := newTestBuffer(t, WithSink(sink))
+	registry := logFile.registry
+
+	logger.Info("a")
+	logger.Info("b")
+	logger.Error("c")
+	used := registry.MemoryUsage()
+
+	result, err := logFile.Flush()
+	if err == nil {
+		t.Fatal("flush through a failing sink returned no error")
+	}
+	if result.Lines != 1 || result.Failed != 2 {
+		t.Errorf("sent %d and failed %d lines, want 1 and 2", result.Lines, result.Failed)
+	}
+	if got, want := messages(logFile.Records()), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v after the failed flush, want %v", got, want)
+	}
+	if !logFile.ErrorHappened() {
+		t.Error("buffer is no longer marked after the failed flush")
+	}
+	if got := registry.MemoryUsage(); got >= used || got != int64(logFile.buffer.size) {
+		t.Errorf("registry accounts %d bytes for a buffer of %d bytes, %d before the flush", got, logFile.buffer.size, used)
+	}
+
+	//Entries logged after the failed flush come after the requeued ones
+	logger.Info("d")
+	sink.n = 10
+	if _, err := logFile.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := messages(sink.Records()), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sink received %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,20 @@
+package log
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+//Levels makes LFile a logrus.Hook capturing entries of every level
+func (logFile *LFile) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+//Fire stores entry in the buffer and evaluates the trigger policy for it
+//Any logrus.Logger can fill the buffer by adding the LFile as hook, e.g. logrus.StandardLogger().AddHook(logFile)
+func (logFile *LFile) Fire(entry *logrus.Entry) error {
+	if logFile.config.splitLevels && entry.Level > logFile.captureLevel() {
+		return nil
+	}
+	logFile.Capture(recordFromEntry(entry))
+	return nil
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//withStandardLoggerHook adds logFile as hook to the standard logger for the duration of the test
+func withStandardLoggerHook(t *testing.T, logFile *LFile) {
+	std := logrus.StandardLogger()
+	hooks := std.ReplaceHooks(make(logrus.LevelHooks))
+	out := std.Out
+	std.AddHook(logFile)
+	std.SetOutput(ioutil.Discard)
+	t.Cleanup(func() {
+		std.ReplaceHooks(hooks)
+		std.SetOutput(out)
+	})
+}
+
+func TestFireFlushesWithoutDeadlockOnStandardLogger(t *testing.T) {
+	logFile, _, sink := newTestBuffer(t, WithPostTriggerWindow(2, 0))
+	withStandardLoggerHook(t, logFile)
+
+	done := make(chan struct{})
+	go func() {
+		logrus.Info("before")
+		logrus.Error("boom")
+		logrus.Info("after")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging through the standard logger did not return")
+	}
+	logFile.WaitStreamed()
+
+	if got, want := messages(sink.Records()), []string{"before", "boom", "after"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestDiagnosticsStayOutOfBufferOnStandardLogger(t *testing.T) {
+	logFile, _, sink := newTestBuffer(t)
+	withStandardLoggerHook(t, logFile)
+
+	logrus.Error("boom")
+	if _, err := logFile.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	//Without an error this logs that the buffer was cleared
+	if _, err := logFile.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := logFile.Records(); len(records) != 0 {
+		t.Errorf("buffer holds %v after flushing", messages(records))
+	}
+	if logFile.ErrorHappened() {
+		t.Error("buffer is marked after flushing")
+	}
+	if got, want := messages(sink.Records()), []string{"boom"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+//RequestIDHeader is the header the request ID is read from, the ID is echoed in it on the response
+var RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+//RequestIDFromContext returns the request ID HTTPMiddleware read or generated for the request of ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+//HTTPMiddleware gives every request a child buffer of parent, carried in the request context
+//The buffer is marked when the handler panics or responds with a 5xx status. At the end of the request
+//it is flushed if marked and thrown away otherwise. A panic is re-raised after flushing.
+//A panic with http.ErrAbortHandler is how a handler aborts a response on purpose, it does not mark the buffer.
+//The request ID is taken from RequestIDHeader or generated, it is added to the fields of the buffer,
+//carried in the context for RequestIDFromContext and set on the response. The request itself is left untouched.
+//
+//	handler := log.HTTPMiddleware(logFile)(mux)
+//	...
+//	log.FromContext(r.Context()).Logger().Debug("loading user")
+func HTTPMiddleware(parent *LFile) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+			ctx, requestLog := parent.BeginRequest(ctx, logrus.Fields{
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"request_id": requestID,
+			})
+			recorder := &statusRecorder{ResponseWriter: w}
+
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					if recovered != http.ErrAbortHandler {
+						RecordPanic(requestLog, recovered)
+					}
+					finishRequest(requestLog)
+					panic(recovered)
+				}
+
+				if recorder.status >= http.StatusInternalServerError {
+					requestLog.Logger().WithField("status", recorder.status).Error("Request failed")
+					requestLog.MarkError()
+				}
+				finishRequest(requestLog)
+			}()
+
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+		})
+	}
+}
+
+//finishRequest finishes a request buffer, a failing flush is logged since there is no caller to return it to
+func finishRequest(requestLog *LFile) {
+	if _, err := requestLog.Finish(); err != nil {
+		Diagnostics.Error(err)
+	}
+}
+
+//newRequestID returns a random request ID
+func newRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+//statusRecorder remembers the status code written to a ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+//Unwrap gives http.ResponseController access to the original ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+//Flush supports streaming handlers
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Hijack supports websockets and other handlers taking over the connection
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not support hijacking", r.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+//Push supports HTTP/2 server push
+func (r *statusRecorder) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := r.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
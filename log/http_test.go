@@ -0,0 +1,136 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		flushed   bool
+		recovered interface{}
+	}{
+		{"ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, false, nil},
+		{"client error", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }, false, nil},
+		{"server error", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) }, true, nil},
+		{"panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") }, true, "boom"},
+		{"aborted", func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }, false, http.ErrAbortHandler},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, _, sink := newTestBuffer(t)
+			handler := HTTPMiddleware(parent)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				FromContext(r.Context()).Logger().Info("handling")
+				test.handler(w, r)
+			}))
+
+			var recovered interface{}
+			func() {
+				defer func() { recovered = recover() }()
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+			}()
+			if recovered != test.recovered {
+				t.Errorf("recovered %v, want %v", recovered, test.recovered)
+			}
+			if flushed := len(sink.Batches()) > 0; flushed != test.flushed {
+				t.Errorf("flushed %v, want %v", flushed, test.flushed)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"from the header", "abc123"},
+		{"generated", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, _, sink := newTestBuffer(t)
+			var seen string
+			handler := HTTPMiddleware(parent)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+				if got := r.Header.Get(RequestIDHeader); got != test.header {
+					t.Errorf("handler sees header %q, want the request untouched", got)
+				}
+				FromContext(r.Context()).Logger().Info("handling")
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+
+			request := httptest.NewRequest("GET", "/users", nil)
+			if test.header != "" {
+				request.Header.Set(RequestIDHeader, test.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+
+			if seen == "" || (test.header != "" && seen != test.header) {
+				t.Errorf("request ID %q in the context, header %q", seen, test.header)
+			}
+			if got := request.Header.Get(RequestIDHeader); got != test.header {
+				t.Errorf("request header changed to %q", got)
+			}
+			if got := w.Header().Get(RequestIDHeader); got != seen {
+				t.Errorf("response header %q, want %q", got, seen)
+			}
+			records := sink.Records()
+			if len(records) == 0 {
+				t.Fatal("failed request was not flushed")
+			}
+			for _, record := range records {
+				if record.Fields["request_id"] != seen {
+					t.Errorf("record %q has request_id %v, want %q", record.Message, record.Fields["request_id"], seen)
+				}
+			}
+		})
+	}
+	if got := RequestIDFromContext(httptest.NewRequest("GET", "/", nil).Context()); got != "" {
+		t.Errorf("request ID %q outside the middleware", got)
+	}
+}
+
+func TestHTTPMiddlewareHijack(t *testing.T) {
+	parent, _, _ := newTestBuffer(t)
+	server := httptest.NewServer(HTTPMiddleware(parent)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buffered, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		buffered.Flush()
+	})))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status %d, want %d", response.StatusCode, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestStatusRecorderWithoutHijacker(t *testing.T) {
+	recorder := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := recorder.Hijack(); err == nil {
+		t.Error("hijacked a ResponseWriter that does not support it")
+	}
+	if err := recorder.Push("/style.css", nil); err != http.ErrNotSupported {
+		t.Errorf("push returned %v, want %v", err, http.ErrNotSupported)
+	}
+}
+
+var _ interface {
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+} = &statusRecorder{}
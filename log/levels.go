@@ -0,0 +1,55 @@
+package log
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+//splitLevels lets logger print entries up to consoleLevel and pass entries up to bufferLevel to its hooks
+//The logger runs at the most verbose of both, the console is filtered by the formatter and the buffer in Fire
+func splitLevels(logger *logrus.Logger, consoleLevel logrus.Level, bufferLevel logrus.Level) {
+	level := consoleLevel
+	if bufferLevel > level {
+		level = bufferLevel
+	}
+	logger.SetLevel(level)
+	logger.SetFormatter(&consoleFormatter{Formatter: logger.Formatter, level: consoleLevel})
+	logger.SetOutput(nonEmptyWriter{logger.Out})
+}
+
+//captureLevel returns the most verbose level the buffer captures, raised to the escalation level while escalated
+func (logFile *LFile) captureLevel() logrus.Level {
+	logFile.mu.Lock()
+	defer logFile.mu.Unlock()
+	if logFile.escalated && logFile.config.escalateLevel > logFile.config.bufferLevel {
+		return logFile.config.escalateLevel
+	}
+	return logFile.config.bufferLevel
+}
+
+//consoleFormatter only formats entries up to level, the entries below it are only meant for the buffer
+type consoleFormatter struct {
+	logrus.Formatter
+	level logrus.Level
+}
+
+//Format formats entry with the wrapped formatter, or returns nothing if entry is below the console level
+func (f *consoleFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if entry.Level > f.level {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+//nonEmptyWriter skips the empty writes of the entries consoleFormatter filtered out
+type nonEmptyWriter struct {
+	io.Writer
+}
+
+func (w nonEmptyWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return w.Writer.Write(p)
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSplitLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []BufferOption
+		console  []string
+		buffered []string
+	}{
+		{"debug only in the buffer", []BufferOption{WithBufferLevel(logrus.DebugLevel)}, []string{"info", "warn"}, []string{"debug", "info", "warn"}},
+		{"info only in the buffer", []BufferOption{WithConsoleLevel(logrus.WarnLevel)}, []string{"warn"}, []string{"info", "warn"}},
+		{"debug only on the console", []BufferOption{WithConsoleLevel(logrus.DebugLevel)}, []string{"debug", "info", "warn"}, []string{"info", "warn"}},
+		{"both", []BufferOption{WithConsoleLevel(logrus.WarnLevel), WithBufferLevel(logrus.DebugLevel)}, []string{"warn"}, []string{"debug", "info", "warn"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logFile, logger, _ := newTestBuffer(t, test.opts...)
+			var out bytes.Buffer
+			logger.Logger.SetOutput(nonEmptyWriter{&out})
+
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.Warn("warn")
+
+			var console []string
+			decoder := json.NewDecoder(&out)
+			for decoder.More() {
+				var line map[string]interface{}
+				if err := decoder.Decode(&line); err != nil {
+					t.Fatal(err)
+				}
+				console = append(console, line[logrus.FieldKeyMsg].(string))
+			}
+			if !reflect.DeepEqual(console, test.console) {
+				t.Errorf("printed %v, want %v", console, test.console)
+			}
+			if got := messages(logFile.Records()); !reflect.DeepEqual(got, test.buffered) {
+				t.Errorf("buffered %v, want %v", got, test.buffered)
+			}
+		})
+	}
+}
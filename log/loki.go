@@ -0,0 +1,200 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+//LokiEncoding is the body encoding a LokiSink pushes with
+type LokiEncoding int
+
+const (
+	//LokiProtobuf pushes snappy-compressed protobuf, the encoding Promtail uses
+	LokiProtobuf LokiEncoding = iota
+	//LokiJSON pushes the JSON encoding of the push API
+	LokiJSON
+)
+
+//lokiPushPath is the path of the Loki push API
+const lokiPushPath = "/loki/api/v1/push"
+
+//LokiTimeout bounds a push of a LokiSink without its own Client
+//Pushes can run inside a logging call, a Loki that hangs must not block the logger forever
+var LokiTimeout = 10 * time.Second
+
+//LokiSink pushes records straight to Loki, without a fluentd in between
+//Every batch becomes one stream, labelled with service_name, service_info and Labels
+type LokiSink struct {
+	//URL is the base URL of Loki, e.g. http://loki:3100
+	URL string
+	//Encoding is the body encoding of the push requests
+	Encoding LokiEncoding
+	//Labels are added to the labels of every stream
+	Labels map[string]string
+	//TenantID is sent as X-Scope-OrgID when Loki runs multi-tenant
+	TenantID string
+	//Client sends the push requests, a client with LokiTimeout is used if nil
+	Client *http.Client
+}
+
+//NewLokiSink creates a sink pushing to the Loki at url with the given encoding
+func NewLokiSink(url string, encoding LokiEncoding) *LokiSink {
+	return &LokiSink{URL: url, Encoding: encoding}
+}
+
+//Send pushes all records of batch in one request, either all or none of them are delivered
+func (s *LokiSink) Send(batch Batch) (int, error) {
+	if len(batch.Records) == 0 {
+		return 0, nil
+	}
+
+	entries := make([]lokiEntry, len(batch.Records))
+	for i, record := range batch.Records {
+		line, err := json.Marshal(batch.recordData(record))
+		if err != nil {
+			return 0, err
+		}
+		entries[i] = lokiEntry{record.Time, string(line)}
+	}
+	//Loki rejects out of order entries within a stream
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].time.Before(entries[j].time) })
+
+	labels := s.streamLabels(batch)
+	var body []byte
+	var contentType string
+	switch s.Encoding {
+	case LokiJSON:
+		var err error
+		if body, err = lokiJSONBody(labels, entries); err != nil {
+			return 0, err
+		}
+		contentType = "application/json"
+	default:
+		body = snappy.Encode(nil, lokiProtobufBody(labels, entries))
+		contentType = "application/x-protobuf"
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.Destination(), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	if s.TenantID != "" {
+		request.Header.Set("X-Scope-OrgID", s.TenantID)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: LokiTimeout}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return 0, fmt.Errorf("loki push returned %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	return len(batch.Records), nil
+}
+
+//Destination returns the URL of the push API
+func (s *LokiSink) Destination() string {
+	return strings.TrimSuffix(s.URL, "/") + lokiPushPath
+}
+
+//streamLabels returns the labels of the stream a batch is pushed to
+func (s *LokiSink) streamLabels(batch Batch) map[string]string {
+	labels := make(map[string]string, len(s.Labels)+2)
+	for name, value := range s.Labels {
+		labels[name] = value
+	}
+	labels["service_name"] = batch.ServiceName
+	labels["service_info"] = batch.ServiceInfo
+	return labels
+}
+
+//lokiEntry is one log line of a stream
+type lokiEntry struct {
+	time time.Time
+	line string
+}
+
+//lokiJSONBody encodes a push request with one stream in the JSON encoding
+func lokiJSONBody(labels map[string]string, entries []lokiEntry) ([]byte, error) {
+	values := make([][2]string, len(entries))
+	for i, entry := range entries {
+		values[i] = [2]string{strconv.FormatInt(entry.time.UnixNano(), 10), entry.line}
+	}
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	return json.Marshal(struct {
+		Streams []stream `json:"streams"`
+	}{[]stream{{labels, values}}})
+}
+
+//lokiProtobufBody encodes a push request with one stream as logproto.PushRequest:
+//
+//	PushRequest   { repeated StreamAdapter streams = 1; }
+//	StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter  { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func lokiProtobufBody(labels map[string]string, entries []lokiEntry) []byte {
+	var stream []byte
+	stream = protowire.AppendTag(stream, 1, protowire.BytesType)
+	stream = protowire.AppendString(stream, lokiLabelString(labels))
+	for _, entry := range entries {
+		var timestamp []byte
+		//Zero values are left out, like proto3 does
+		if seconds := entry.time.Unix(); seconds != 0 {
+			timestamp = protowire.AppendTag(timestamp, 1, protowire.VarintType)
+			timestamp = protowire.AppendVarint(timestamp, uint64(seconds))
+		}
+		if nanos := entry.time.Nanosecond(); nanos != 0 {
+			timestamp = protowire.AppendTag(timestamp, 2, protowire.VarintType)
+			timestamp = protowire.AppendVarint(timestamp, uint64(nanos))
+		}
+
+		var message []byte
+		message = protowire.AppendTag(message, 1, protowire.BytesType)
+		message = protowire.AppendBytes(message, timestamp)
+		message = protowire.AppendTag(message, 2, protowire.BytesType)
+		message = protowire.AppendString(message, entry.line)
+
+		stream = protowire.AppendTag(stream, 2, protowire.BytesType)
+		stream = protowire.AppendBytes(stream, message)
+	}
+
+	request := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendBytes(request, stream)
+}
+
+//lokiLabelString formats labels as a LogQL stream selector, e.g. {a="b", c="d"}
+func lokiLabelString(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -0,0 +1,281 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+//lokiRequest is a push request received by lokiServer
+type lokiRequest struct {
+	header http.Header
+	path   string
+	body   []byte
+}
+
+//lokiServer is a stand-in for the Loki push API, it responds with status and passes every request on
+func lokiServer(t *testing.T, status int) (*httptest.Server, <-chan lokiRequest) {
+	requests := make(chan lokiRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		requests <- lokiRequest{r.Header, r.URL.Path, body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+//lokiTestBatch returns a batch with records out of order, Loki needs them sorted by time
+func lokiTestBatch() Batch {
+	base := time.Date(2019, 5, 23, 10, 0, 0, 0, time.UTC)
+	return Batch{
+		Tag:         "service.test",
+		ServiceName: "service",
+		ServiceInfo: "test",
+		Reason:      FlushReasonError,
+		Records: []Record{
+			{Time: base.Add(2 * time.Millisecond), Level: logrus.ErrorLevel, Message: "second"},
+			{Time: base.Add(time.Millisecond), Level: logrus.InfoLevel, Message: "first", Fields: logrus.Fields{"user": "42"}},
+		},
+	}
+}
+
+//decodedEntry is a log line of a decoded push request
+type decodedEntry struct {
+	time time.Time
+	line map[string]interface{}
+}
+
+//protoField is a field of a protobuf message, value holds varints and bytes the length-delimited fields
+type protoField struct {
+	number int
+	value  uint64
+	bytes  []byte
+}
+
+//decodeProto splits a protobuf message into its varint and length-delimited fields
+func decodeProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		field := protoField{number: int(number)}
+		switch wireType {
+		case protowire.VarintType:
+			field.value, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			return nil, fmt.Errorf("proto: unexpected wire type %d", wireType)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+//decodeLokiProtobuf decodes a snappy-compressed logproto.PushRequest with one stream
+func decodeLokiProtobuf(t *testing.T, body []byte) (string, []decodedEntry) {
+	t.Helper()
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := decodeProto(raw)
+	if err != nil || len(request) != 1 || request[0].number != 1 {
+		t.Fatalf("push request %v, %v, want one stream", request, err)
+	}
+	stream, err := decodeProto(request[0].bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var labels string
+	var entries []decodedEntry
+	for _, field := range stream {
+		switch field.number {
+		case 1:
+			labels = string(field.bytes)
+		case 2:
+			entryFields, err := decodeProto(field.bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var entry decodedEntry
+			for _, entryField := range entryFields {
+				switch entryField.number {
+				case 1:
+					timestamp, err := decodeProto(entryField.bytes)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var seconds, nanos uint64
+					for _, part := range timestamp {
+						if part.number == 1 {
+							seconds = part.value
+						} else if part.number == 2 {
+							nanos = part.value
+						}
+					}
+					entry.time = time.Unix(int64(seconds), int64(nanos)).UTC()
+				case 2:
+					if err := json.Unmarshal(entryField.bytes, &entry.line); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return labels, entries
+}
+
+//checkLokiEntries checks that entries hold the records of lokiTestBatch, sorted by time
+func checkLokiEntries(t *testing.T, entries []decodedEntry) {
+	t.Helper()
+	batch := lokiTestBatch()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for i, record := range []Record{batch.Records[1], batch.Records[0]} {
+		if !entries[i].time.Equal(record.Time) {
+			t.Errorf("entry %d has time %v, want %v", i, entries[i].time, record.Time)
+		}
+		if entries[i].line["msg"] != record.Message {
+			t.Errorf("entry %d has msg %v, want %q", i, entries[i].line["msg"], record.Message)
+		}
+		if entries[i].line[FlushReasonKey] != string(FlushReasonError) {
+			t.Errorf("entry %d has %s %v", i, FlushReasonKey, entries[i].line[FlushReasonKey])
+		}
+	}
+	if entries[0].line["user"] != "42" {
+		t.Errorf("entry fields were not kept: %v", entries[0].line)
+	}
+}
+
+func TestLokiSinkProtobuf(t *testing.T) {
+	server, requests := lokiServer(t, http.StatusNoContent)
+	sink := NewLokiSink(server.URL+"/", LokiProtobuf)
+	sink.Labels = map[string]string{"env": "prod"}
+	sink.TenantID = "tenant"
+
+	sent, err := sink.Send(lokiTestBatch())
+	if err != nil || sent != 2 {
+		t.Fatalf("Send returned %d, %v", sent, err)
+	}
+
+	request := <-requests
+	if request.path != lokiPushPath {
+		t.Errorf("pushed to %s", request.path)
+	}
+	if got := request.header.Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("Content-Type %q", got)
+	}
+	if got := request.header.Get("X-Scope-OrgID"); got != "tenant" {
+		t.Errorf("X-Scope-OrgID %q", got)
+	}
+	labels, entries := decodeLokiProtobuf(t, request.body)
+	if want := `{env="prod", service_info="test", service_name="service"}`; labels != want {
+		t.Errorf("labels %s, want %s", labels, want)
+	}
+	checkLokiEntries(t, entries)
+}
+
+func TestLokiSinkJSON(t *testing.T) {
+	server, requests := lokiServer(t, http.StatusNoContent)
+	sink := NewLokiSink(server.URL, LokiJSON)
+
+	sent, err := sink.Send(lokiTestBatch())
+	if err != nil || sent != 2 {
+		t.Fatalf("Send returned %d, %v", sent, err)
+	}
+
+	request := <-requests
+	if got := request.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type %q", got)
+	}
+	if got := request.header.Get("X-Scope-OrgID"); got != "" {
+		t.Errorf("X-Scope-OrgID %q without a tenant", got)
+	}
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(request.body, &push); err != nil {
+		t.Fatal(err)
+	}
+	if len(push.Streams) != 1 {
+		t.Fatalf("got %d streams, want 1", len(push.Streams))
+	}
+	if want := map[string]string{"service_name": "service", "service_info": "test"}; !reflect.DeepEqual(push.Streams[0].Stream, want) {
+		t.Errorf("labels %v, want %v", push.Streams[0].Stream, want)
+	}
+	var entries []decodedEntry
+	for _, value := range push.Streams[0].Values {
+		nanos, err := strconv.ParseInt(value[0], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := decodedEntry{time: time.Unix(0, nanos).UTC()}
+		if err := json.Unmarshal([]byte(value[1]), &entry.line); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	checkLokiEntries(t, entries)
+}
+
+func TestLokiSinkRejected(t *testing.T) {
+	server, requests := lokiServer(t, http.StatusBadRequest)
+	sink := NewLokiSink(server.URL, LokiProtobuf)
+
+	sent, err := sink.Send(lokiTestBatch())
+	<-requests
+	if err == nil || sent != 0 {
+		t.Errorf("Send returned %d, %v, want an error and nothing sent", sent, err)
+	}
+}
+
+func TestLokiSinkTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	timeout := LokiTimeout
+	LokiTimeout = 50 * time.Millisecond
+	defer func() { LokiTimeout = timeout }()
+
+	start := time.Now()
+	sent, err := NewLokiSink(server.URL, LokiJSON).Send(lokiTestBatch())
+	if err == nil || sent != 0 {
+		t.Errorf("Send returned %d, %v, want a timeout", sent, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send took %v", elapsed)
+	}
+}
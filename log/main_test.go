@@ -0,0 +1,33 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	Diagnostics.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+//newTestBuffer creates a buffer in its own registry, flushing into a MemorySink and printing nothing
+func newTestBuffer(t testing.TB, opts ...BufferOption) (*LFile, *logrus.Entry, *MemorySink) {
+	t.Helper()
+	sink := &MemorySink{}
+	registry := NewRegistry(0)
+	logFile, logger := registry.CreateLogBuffer("service", "test", 0, "", append([]BufferOption{WithSink(sink)}, opts...)...)
+	logger.Logger.SetOutput(ioutil.Discard)
+	return logFile, logger, sink
+}
+
+//messages returns the messages of records
+func messages(records []Record) []string {
+	result := make([]string, len(records))
+	for i, record := range records {
+		result[i] = record.Message
+	}
+	return result
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	//DefaultMaxEntries is the amount of log entries a buffer keeps when no WithMaxEntries option is given
+	DefaultMaxEntries = 1000
+	//DefaultMaxBytes is the amount of bytes a buffer keeps when no WithMaxBytes option is given
+	DefaultMaxBytes = 1 << 20
+)
+
+//BufferOption configures a buffer when it is created
+type BufferOption func(*bufferConfig)
+
+//bufferConfig holds the settings a buffer is created with
+type bufferConfig struct {
+	maxEntries int
+	maxBytes   int
+	sink       Sink
+	triggers   TriggerPolicy
+	//preTrigger and postTrigger bound the context kept before and streamed after a trigger
+	preTrigger  triggerWindow
+	postTrigger triggerWindow
+	//escalateLevel is the level the logger drops to for escalateFor after a trigger
+	escalateLevel logrus.Level
+	escalateFor   time.Duration
+	//splitLevels is set when the console and the buffer have their own level, otherwise both follow the logger level
+	splitLevels  bool
+	consoleLevel logrus.Level
+	bufferLevel  logrus.Level
+}
+
+//streamWindow is the window streamed to the sink after a trigger, an escalation streams its whole cooldown
+func (config bufferConfig) streamWindow() triggerWindow {
+	if config.escalateFor > 0 {
+		return triggerWindow{duration: config.escalateFor}
+	}
+	return config.postTrigger
+}
+
+//triggerWindow bounds the entries around a trigger by amount and by time, a limit of 0 does not apply
+type triggerWindow struct {
+	entries  int
+	duration time.Duration
+}
+
+//enabled reports whether any limit of the window is set
+func (w triggerWindow) enabled() bool {
+	return w.entries > 0 || w.duration > 0
+}
+
+func newBufferConfig(opts []BufferOption) bufferConfig {
+	config := bufferConfig{
+		maxEntries: DefaultMaxEntries,
+		maxBytes:   DefaultMaxBytes,
+		triggers:   DefaultTriggerPolicy(),
+		//The level a logrus.Logger starts at
+		consoleLevel: logrus.InfoLevel,
+		bufferLevel:  logrus.InfoLevel,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+//WithMaxEntries keeps only the last n entries in the buffer, 0 means no limit on the amount of entries
+func WithMaxEntries(n int) BufferOption {
+	return func(config *bufferConfig) {
+		config.maxEntries = n
+	}
+}
+
+//WithMaxBytes keeps only the last n bytes of entries in the buffer, 0 means no limit on the size
+func WithMaxBytes(n int) BufferOption {
+	return func(config *bufferConfig) {
+		config.maxBytes = n
+	}
+}
+
+//WithSink sends the buffer to sink when it is flushed, instead of to the fluentd given at creation
+func WithSink(sink Sink) BufferOption {
+	return func(config *bufferConfig) {
+		config.sink = sink
+	}
+}
+
+//WithTriggerPolicy decides which entries mark the buffer for flushing, instead of DefaultTriggerPolicy
+func WithTriggerPolicy(policy TriggerPolicy) BufferOption {
+	return func(config *bufferConfig) {
+		config.triggers = policy
+	}
+}
+
+//WithPreTriggerWindow keeps at most n entries, and only those of the last d, before the first trigger since the last flush
+//A limit of 0 does not apply, the buffer limits always do
+func WithPreTriggerWindow(n int, d time.Duration) BufferOption {
+	return func(config *bufferConfig) {
+		config.preTrigger = triggerWindow{n, d}
+	}
+}
+
+//WithPostTriggerWindow flushes the buffer as soon as a trigger matches and streams the next n entries,
+//or the entries of the next d, straight to the sink. Afterwards the buffer goes back to buffering.
+//When both are set the window ends at whichever limit is reached first, a trigger inside the window restarts it.
+//Streamed entries are sent in batches from a goroutine, so logging never waits for the sink, see MaxStreamQueue.
+func WithPostTriggerWindow(n int, d time.Duration) BufferOption {
+	return func(config *bufferConfig) {
+		config.postTrigger = triggerWindow{n, d}
+	}
+}
+
+//WithEscalation drops the logger of the buffer to level, e.g. logrus.DebugLevel, for cooldown after a trigger
+//and streams every entry of the cooldown straight to the sink, in place of WithPostTriggerWindow.
+//A trigger during the cooldown restarts it, afterwards the logger returns to the level it had before.
+func WithEscalation(level logrus.Level, cooldown time.Duration) BufferOption {
+	return func(config *bufferConfig) {
+		config.escalateLevel = level
+		config.escalateFor = cooldown
+	}
+}
+
+//WithConsoleLevel only prints entries up to level to the console, independent of the level of the buffer
+//A buffer with WithConsoleLevel or WithBufferLevel keeps the other one at logrus.InfoLevel unless it is given as well
+func WithConsoleLevel(level logrus.Level) BufferOption {
+	return func(config *bufferConfig) {
+		config.splitLevels = true
+		config.consoleLevel = level
+	}
+}
+
+//WithBufferLevel captures entries up to level in the buffer, independent of the level of the console
+//E.g. WithBufferLevel(logrus.DebugLevel) records debug lines only in the buffer, to be shipped when an error happens
+func WithBufferLevel(level logrus.Level) BufferOption {
+	return func(config *bufferConfig) {
+		config.splitLevels = true
+		config.bufferLevel = level
+	}
+}
@@ -0,0 +1,73 @@
+package log
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//Record is one buffered log entry
+//Records are kept unformatted, they are only encoded by Data or a Sink when they are flushed or read.
+//Field values are referenced rather than copied, so values that are modified after logging show the modified value.
+type Record struct {
+	Time    time.Time
+	Level   logrus.Level
+	Message string
+	Fields  map[string]interface{}
+	//Caller is the calling function, it is only set when the logger reports callers
+	Caller *runtime.Frame
+}
+
+//Data returns the record as a flat map, laid out like the output of logrus.JSONFormatter
+func (r Record) Data() map[string]interface{} {
+	data := make(map[string]interface{}, len(r.Fields)+5)
+	for key, value := range r.Fields {
+		//Errors would otherwise be encoded as an empty object
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		data[key] = value
+	}
+	data[logrus.FieldKeyTime] = r.Time.Format(time.RFC3339)
+	data[logrus.FieldKeyLevel] = r.Level.String()
+	data[logrus.FieldKeyMsg] = r.Message
+	if r.Caller != nil {
+		data[logrus.FieldKeyFunc] = r.Caller.Function
+		data[logrus.FieldKeyFile] = fmt.Sprintf("%s:%d", r.Caller.File, r.Caller.Line)
+	}
+	return data
+}
+
+//recordOverhead approximates the bytes a record takes besides its message and fields
+const recordOverhead = 64
+
+//size estimates the memory a record holds, without encoding it
+func (r Record) size() int {
+	size := recordOverhead + len(r.Message)
+	for key, value := range r.Fields {
+		size += len(key)
+		switch value := value.(type) {
+		case string:
+			size += len(value)
+		case []byte:
+			size += len(value)
+		default:
+			size += 16
+		}
+	}
+	return size
+}
+
+//recordFromEntry converts a logrus entry into a Record
+//The fields are not copied, logrus does not modify the fields of an entry once it is created
+func recordFromEntry(entry *logrus.Entry) Record {
+	return Record{
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Message: entry.Message,
+		Fields:  entry.Data,
+		Caller:  entry.Caller,
+	}
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/sirupsen/logrus"
+)
+
+//Recover flushes logFile when the calling goroutine panics, and then panics again with the same value
+//The panic value and stack trace are recorded into the buffer first. It must be deferred directly:
+//
+//	defer log.Recover(logFile)
+func Recover(logFile *LFile) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	RecordPanic(logFile, recovered)
+	if _, err := logFile.Flush(); err != nil {
+		Diagnostics.Error(err)
+	}
+	panic(recovered)
+}
+
+//Go runs fn in a new goroutine that flushes logFile if fn panics, see Recover
+func Go(logFile *LFile, fn func()) {
+	go func() {
+		defer Recover(logFile)
+		fn()
+	}()
+}
+
+//RecordPanic logs a recovered panic value with the current stack trace into logFile and marks the buffer
+//It is meant to be called from a deferred function that recovered the panic
+func RecordPanic(logFile *LFile, recovered interface{}) {
+	logFile.Logger().WithFields(logrus.Fields{
+		"panic": fmt.Sprint(recovered),
+		"stack": string(debug.Stack()),
+	}).Error("Panic recovered")
+	logFile.MarkError()
+}
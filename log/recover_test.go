@@ -0,0 +1,91 @@
+package log
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	logFile, logger, sink := newTestBuffer(t)
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		defer Recover(logFile)
+		logger.Info("before")
+		panic("boom")
+	}()
+	if recovered != "boom" {
+		t.Errorf("recovered %v, want the panic raised again", recovered)
+	}
+
+	batches := sink.Batches()
+	if len(batches) != 1 || batches[0].Reason != FlushReasonError {
+		t.Fatalf("sent %d batches, want one error batch", len(batches))
+	}
+	if got, want := messages(batches[0].Records), []string{"before", "Panic recovered"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flushed %v, want %v", got, want)
+	}
+	fields := batches[0].Records[1].Fields
+	if fields["panic"] != "boom" || !strings.Contains(fields["stack"].(string), "TestRecover") {
+		t.Errorf("panic recorded with %v", fields)
+	}
+	if logFile.ErrorHappened() || len(logFile.Records()) != 0 {
+		t.Errorf("buffer after the flush: %+v", logFile.Stats())
+	}
+}
+
+func TestRecoverWithoutPanic(t *testing.T) {
+	logFile, logger, sink := newTestBuffer(t)
+
+	func() {
+		defer Recover(logFile)
+		logger.Info("fine")
+	}()
+	if len(sink.Batches()) != 0 || logFile.ErrorHappened() {
+		t.Errorf("flushed %v without a panic", messages(sink.Records()))
+	}
+}
+
+func TestGo(t *testing.T) {
+	logFile, logger, sink := newTestBuffer(t)
+
+	done := make(chan struct{})
+	Go(logFile, func() {
+		defer close(done)
+		logger.Info("fine")
+	})
+	<-done
+	if len(sink.Batches()) != 0 || logFile.ErrorHappened() {
+		t.Errorf("flushed %v without a panic", messages(sink.Records()))
+	}
+}
+
+//TestGoFlushesBeforeCrash runs itself in a new process, as the panic raised again by Go ends the process
+func TestGoFlushesBeforeCrash(t *testing.T) {
+	if os.Getenv("BM_LOGGING_GO_CRASH") == "1" {
+		registry := NewRegistry(0)
+		logFile, logger := registry.CreateLogBuffer("service", "crash", 0, "", WithSink(NewWriterSink(os.Stdout)))
+		logger.Logger.SetOutput(os.Stderr)
+		Go(logFile, func() {
+			logger.Info("before")
+			panic("boom")
+		})
+		select {}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGoFlushesBeforeCrash$")
+	cmd.Env = append(os.Environ(), "BM_LOGGING_GO_CRASH=1")
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); !ok {
+		t.Fatalf("process ended with %v, want a crash", err)
+	}
+	for _, want := range []string{`"msg":"before"`, `"msg":"Panic recovered"`, `"panic":"boom"`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("flushed output misses %s:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package log
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+//bufferKey identifies a buffer in a Registry
+type bufferKey struct {
+	serviceName string
+	serviceInfo string
+}
+
+//Registry keeps track of the log buffers of a process, it is safe for concurrent use
+type Registry struct {
+	//used and budget are accessed atomically and kept first for 64-bit alignment
+	used   int64
+	budget int64
+
+	mu           sync.Mutex
+	budgetPolicy BudgetPolicy
+	maxBuffers   int
+	buffers      map[bufferKey]*LFile
+	//order holds the keys from oldest to newest, used to evict buffers when the registry is full
+	order []bufferKey
+	//children holds the request-scoped buffers, they count towards the memory budget
+	children           map[*LFile]struct{}
+	flushAllOnShutdown bool
+	exitHandlerOnce    sync.Once
+	//fluentClients holds one fluentd connection per host:port, shared by the buffers
+	fluentClients map[string]*fluentClient
+	//fluentSinks holds the shared sinks per host:port and options, sinks to the same host:port use the same connection
+	fluentSinks map[fluentSinkKey]*FluentSink
+}
+
+//fluentSinkKey identifies a shared FluentSink by its destination and options
+type fluentSinkKey struct {
+	destination   string
+	keepTimeField bool
+}
+
+//defaultRegistry backs the package-level functions
+var defaultRegistry = NewRegistry(0)
+
+//NewRegistry creates an empty registry holding at most maxBuffers buffers
+//If maxBuffers is 0, the package-level MaxNumberOfBuffers is used
+func NewRegistry(maxBuffers int) *Registry {
+	return &Registry{
+		maxBuffers:    maxBuffers,
+		buffers:       make(map[bufferKey]*LFile),
+		children:      make(map[*LFile]struct{}),
+		fluentClients: make(map[string]*fluentClient),
+		fluentSinks:   make(map[fluentSinkKey]*FluentSink),
+	}
+}
+
+//DefaultRegistry returns the registry used by the package-level functions
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+//CreateLogBuffer creates an in-memory buffer in the registry, or returns the existing one for these credentials
+func (r *Registry) CreateLogBuffer(serviceName string, serviceInfo string, fluentPort int, fluentHost string, opts ...BufferOption) (*LFile, *logrus.Entry) {
+	key := bufferKey{serviceName, serviceInfo}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	//if LFile already exists, return it
+	if existing, ok := r.buffers[key]; ok {
+		Diagnostics.Warn("Buffer already exists, returning existing buffer")
+		return existing, existing.entry
+	}
+
+	config := newBufferConfig(opts)
+	if config.sink == nil {
+		config.sink = r.fluentSink(fluentHost, fluentPort)
+	}
+	logFile := newLogBuffer(serviceName, serviceInfo, fluentPort, fluentHost, config)
+	logFile.registry = r
+
+	//If there isn't room in the registry, drop the oldest buffer
+	for len(r.order) > 0 && len(r.order) >= r.limit() {
+		r.dropOldest()
+	}
+	r.buffers[key] = logFile
+	r.order = append(r.order, key)
+
+	return logFile, logFile.entry
+}
+
+//GetLogBufferAndLogger returns the buffer and logger registered for these credentials
+//A nil LFile and a nil entry are returned if there is none
+func (r *Registry) GetLogBufferAndLogger(serviceName string, serviceInfo string) (*LFile, *logrus.Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.buffers[bufferKey{serviceName, serviceInfo}]; ok {
+		return existing, existing.entry
+	}
+	return nil, nil
+}
+
+//Contains reports whether a buffer is registered for these credentials
+func (r *Registry) Contains(serviceName string, serviceInfo string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.buffers[bufferKey{serviceName, serviceInfo}]
+	return ok
+}
+
+//Len returns the amount of registered buffers
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.order)
+}
+
+//SetMaxBuffers changes the amount of buffers the registry holds, the oldest buffers are dropped if needed
+func (r *Registry) SetMaxBuffers(amount int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maxBuffers = amount
+	for len(r.order) > r.limit() {
+		r.dropOldest()
+	}
+}
+
+//dropOldest removes the oldest buffer from the registry, r.mu must be held
+//The buffer keeps working for its holders but no longer counts towards the memory budget
+func (r *Registry) dropOldest() {
+	logFile := r.buffers[r.order[0]]
+	delete(r.buffers, r.order[0])
+	r.order = r.order[1:]
+
+	logFile.mu.Lock()
+	logFile.registry = nil
+	r.account(-logFile.buffer.size)
+	logFile.mu.Unlock()
+}
+
+//track adds a child buffer to the memory accounting of the registry
+func (r *Registry) track(child *LFile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	child.mu.Lock()
+	child.registry = r
+	child.mu.Unlock()
+	r.children[child] = struct{}{}
+}
+
+//untrack removes a child buffer that no longer holds entries from the registry
+func (r *Registry) untrack(child *LFile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.children, child)
+}
+
+//all returns the registered buffers from oldest to newest, followed by the child buffers
+func (r *Registry) all() []*LFile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.allLocked()
+}
+
+//allLocked is all for callers holding r.mu
+func (r *Registry) allLocked() []*LFile {
+	buffers := r.registeredLocked(len(r.children))
+	for child := range r.children {
+		buffers = append(buffers, child)
+	}
+	return buffers
+}
+
+//registered returns the buffers registered by name, oldest first, without child buffers
+func (r *Registry) registered() []*LFile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.registeredLocked(0)
+}
+
+//registeredLocked is registered for callers holding r.mu, with room for extra more buffers
+func (r *Registry) registeredLocked(extra int) []*LFile {
+	buffers := make([]*LFile, 0, len(r.order)+extra)
+	for _, key := range r.order {
+		buffers = append(buffers, r.buffers[key])
+	}
+	return buffers
+}
+
+//FluentSink returns the fluentd sink the registry shares between all buffers sending to host and port with the same options
+//Sinks to the same host and port share one connection, whatever their options
+func (r *Registry) FluentSink(host string, port int, opts ...FluentSinkOption) *FluentSink {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fluentSink(host, port, opts...)
+}
+
+//fluentSink returns the shared sink for host, port and opts, r.mu must be held
+func (r *Registry) fluentSink(host string, port int, opts ...FluentSinkOption) *FluentSink {
+	client := newFluentClient(host, port)
+	if existing, ok := r.fluentClients[client.address()]; ok {
+		client = existing
+	} else {
+		r.fluentClients[client.address()] = client
+	}
+
+	sink := newFluentSink(client, opts)
+	key := fluentSinkKey{destination: client.address(), keepTimeField: sink.keepTimeField}
+	if existing, ok := r.fluentSinks[key]; ok {
+		return existing
+	}
+	r.fluentSinks[key] = sink
+	return sink
+}
+
+//Close closes the fluentd connections of the registry
+//Buffers stay usable, a later flush connects again
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for _, client := range r.fluentClients {
+		if err := client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+//limit returns the maximum amount of buffers, r.mu must be held
+func (r *Registry) limit() int {
+	if r.maxBuffers > 0 {
+		return r.maxBuffers
+	}
+	return MaxNumberOfBuffers
+}
@@ -0,0 +1,148 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+//registeredInfos returns the service info of the registered buffers, oldest first
+func registeredInfos(r *Registry) []string {
+	var infos []string
+	for _, logFile := range r.registered() {
+		infos = append(infos, logFile.ServiceInfo())
+	}
+	return infos
+}
+
+func TestRegistryEvictsOldestBuffer(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBuffers int
+		create     []string
+		want       []string
+	}{
+		{"below the limit", 3, []string{"a", "b"}, []string{"a", "b"}},
+		{"at the limit", 2, []string{"a", "b"}, []string{"a", "b"}},
+		{"over the limit", 2, []string{"a", "b", "c"}, []string{"b", "c"}},
+		{"one buffer", 1, []string{"a", "b", "c"}, []string{"c"}},
+		{"existing buffer is not recreated", 2, []string{"a", "b", "a", "c"}, []string{"b", "c"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewRegistry(test.maxBuffers)
+			for _, info := range test.create {
+				r.CreateLogBuffer("service", info, 0, "", WithSink(&MemorySink{}))
+			}
+			if got := registeredInfos(r); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("registered %v, want %v", got, test.want)
+			}
+			if r.Len() != len(test.want) {
+				t.Errorf("Len is %d, want %d", r.Len(), len(test.want))
+			}
+		})
+	}
+}
+
+func TestRegistryReturnsExistingBuffer(t *testing.T) {
+	r := NewRegistry(0)
+	first, firstLogger := r.CreateLogBuffer("service", "a", 0, "", WithSink(&MemorySink{}))
+	second, secondLogger := r.CreateLogBuffer("service", "a", 0, "", WithSink(&MemorySink{}))
+	if first != second || firstLogger != secondLogger {
+		t.Error("creating a buffer twice returned a new buffer")
+	}
+
+	found, logger := r.GetLogBufferAndLogger("service", "a")
+	if found != first || logger != firstLogger {
+		t.Error("GetLogBufferAndLogger did not return the created buffer")
+	}
+	if found, logger := r.GetLogBufferAndLogger("service", "b"); found != nil || logger != nil {
+		t.Error("GetLogBufferAndLogger returned a buffer that was never created")
+	}
+	if !r.Contains("service", "a") || r.Contains("service", "b") {
+		t.Error("Contains does not match the created buffers")
+	}
+}
+
+func TestRegistriesAreIndependent(t *testing.T) {
+	first := NewRegistry(1)
+	second := NewRegistry(1)
+	firstBuffer, _ := first.CreateLogBuffer("service", "a", 0, "", WithSink(&MemorySink{}))
+	secondBuffer, _ := second.CreateLogBuffer("service", "a", 0, "", WithSink(&MemorySink{}))
+	if firstBuffer == secondBuffer {
+		t.Fatal("two registries share a buffer")
+	}
+
+	second.CreateLogBuffer("service", "b", 0, "", WithSink(&MemorySink{}))
+	if !first.Contains("service", "a") {
+		t.Error("evicting from one registry dropped a buffer of another")
+	}
+}
+
+func TestRegistrySetMaxBuffers(t *testing.T) {
+	r := NewRegistry(5)
+	for _, info := range []string{"a", "b", "c", "d"} {
+		r.CreateLogBuffer("service", info, 0, "", WithSink(&MemorySink{}))
+	}
+	dropped, _ := r.GetLogBufferAndLogger("service", "a")
+
+	r.SetMaxBuffers(2)
+	if got, want := registeredInfos(r), []string{"c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("registered %v, want %v", got, want)
+	}
+
+	//A dropped buffer keeps working for its holders
+	dropped.Logger().Info("still logging")
+	if len(dropped.Records()) != 1 {
+		t.Error("dropped buffer no longer captures entries")
+	}
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	r := NewRegistry(10)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			info := fmt.Sprint(i % 15)
+			logFile, logger := r.CreateLogBuffer("service", info, 0, "", WithSink(&MemorySink{}))
+			logger.Logger.SetOutput(ioutil.Discard)
+			for j := 0; j < 50; j++ {
+				logger.Info("entry ", j)
+				r.GetLogBufferAndLogger("service", info)
+				r.Len()
+			}
+			logger.Error("boom")
+			if _, err := logFile.Flush(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if r.Len() > 10 {
+		t.Errorf("registry holds %d buffers, more than its limit of 10", r.Len())
+	}
+}
+
+func TestPackageLevelFunctionsUseDefaultRegistry(t *testing.T) {
+	defer SetMaxAmountOfBuffers(MaxNumberOfBuffers)
+
+	logFile, logger := CreateLogBuffer("registry-test", "package-level", 0, "", WithSink(&MemorySink{}))
+	found, foundLogger := GetLogBufferAndLogger("registry-test", "package-level")
+	if found != logFile || foundLogger != logger {
+		t.Fatal("GetLogBufferAndLogger did not return the buffer of CreateLogBuffer")
+	}
+	if !DefaultRegistry().Contains("registry-test", "package-level") {
+		t.Fatal("CreateLogBuffer did not register in the default registry")
+	}
+
+	SetMaxAmountOfBuffers(1)
+	CreateLogBuffer("registry-test", "newer", 0, "", WithSink(&MemorySink{}))
+	if DefaultRegistry().Contains("registry-test", "package-level") || DefaultRegistry().Len() != 1 {
+		t.Error("SetMaxAmountOfBuffers did not limit the default registry")
+	}
+}
@@ -0,0 +1,119 @@
+package log
+
+import "time"
+
+//ringBuffer keeps the most recent records, bounded by an amount of entries and/or bytes
+//A limit of 0 means that dimension is unbounded
+//ringBuffer is not safe for concurrent use, the owning LFile guards it
+type ringBuffer struct {
+	//entries[head:] are the buffered records from oldest to newest
+	entries    []ringEntry
+	head       int
+	size       int
+	maxEntries int
+	maxBytes   int
+}
+
+//ringEntry is a buffered record and its estimated size
+type ringEntry struct {
+	record Record
+	size   int
+}
+
+func newRingBuffer(maxEntries int, maxBytes int) *ringBuffer {
+	return &ringBuffer{maxEntries: maxEntries, maxBytes: maxBytes}
+}
+
+//push stores record and evicts the oldest entries to stay within the limits
+func (r *ringBuffer) push(record Record) {
+	size := record.size()
+	r.entries = append(r.entries, ringEntry{record, size})
+	r.size += size
+
+	for r.maxEntries > 0 && r.len() > r.maxEntries {
+		r.evictOldest()
+	}
+	//Always keep the newest entry, even if it is larger than maxBytes on its own
+	for r.maxBytes > 0 && r.size > r.maxBytes && r.len() > 1 {
+		r.evictOldest()
+	}
+}
+
+//requeue puts records back in front of the buffered entries, as their oldest entries
+//The limits are applied as by push, so the oldest records are dropped first
+func (r *ringBuffer) requeue(records []Record) {
+	if len(records) == 0 {
+		return
+	}
+	entries := make([]ringEntry, 0, len(records)+r.len())
+	for _, record := range records {
+		size := record.size()
+		entries = append(entries, ringEntry{record, size})
+		r.size += size
+	}
+	r.entries = append(entries, r.entries[r.head:]...)
+	r.head = 0
+
+	for r.maxEntries > 0 && r.len() > r.maxEntries {
+		r.evictOldest()
+	}
+	for r.maxBytes > 0 && r.size > r.maxBytes && r.len() > 1 {
+		r.evictOldest()
+	}
+}
+
+//evictOldest drops the oldest entry and returns its size
+func (r *ringBuffer) evictOldest() int {
+	if r.len() == 0 {
+		return 0
+	}
+	n := r.entries[r.head].size
+	r.entries[r.head] = ringEntry{}
+	r.head++
+	r.size -= n
+
+	//Compact once half of the backing array is unused
+	if r.head > len(r.entries)/2 {
+		live := copy(r.entries, r.entries[r.head:])
+		for i := live; i < len(r.entries); i++ {
+			r.entries[i] = ringEntry{}
+		}
+		r.entries = r.entries[:live]
+		r.head = 0
+	}
+	return n
+}
+
+//trimContext keeps the newest entry, at most n entries before it and only the entries from since onwards
+//A limit of 0 or a zero since does not apply
+func (r *ringBuffer) trimContext(n int, since time.Time) {
+	for n > 0 && r.len() > n+1 {
+		r.evictOldest()
+	}
+	for !since.IsZero() && r.len() > 1 && r.entries[r.head].record.Time.Before(since) {
+		r.evictOldest()
+	}
+}
+
+//len returns the amount of buffered entries
+func (r *ringBuffer) len() int {
+	return len(r.entries) - r.head
+}
+
+//drain returns the buffered records from oldest to newest and empties the buffer
+func (r *ringBuffer) drain() []Record {
+	records := r.snapshot()
+	r.entries = nil
+	r.head = 0
+	r.size = 0
+	return records
+}
+
+//snapshot returns a copy of the buffered records from oldest to newest, leaving the buffer as is
+func (r *ringBuffer) snapshot() []Record {
+	records := make([]Record, 0, r.len())
+	for _, entry := range r.entries[r.head:] {
+		records = append(records, entry.record)
+	}
+	return records
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//ringMessages pushes records with the given messages into r and returns the buffered messages
+func ringMessages(r *ringBuffer, pushed []string) []string {
+	for _, message := range pushed {
+		r.push(Record{Message: message})
+	}
+	return messages(r.snapshot())
+}
+
+func TestRingBufferLimits(t *testing.T) {
+	//Every record of 10 bytes takes recordOverhead + 10 bytes
+	entry := recordOverhead + 10
+	ten := func(prefix string) string { return prefix + strings.Repeat(".", 10-len(prefix)) }
+
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int
+		pushed     []string
+		want       []string
+	}{
+		{"unbounded", 0, 0, []string{ten("a"), ten("b"), ten("c")}, []string{ten("a"), ten("b"), ten("c")}},
+		{"entry limit", 2, 0, []string{ten("a"), ten("b"), ten("c")}, []string{ten("b"), ten("c")}},
+		{"byte limit", 0, 2 * entry, []string{ten("a"), ten("b"), ten("c")}, []string{ten("b"), ten("c")}},
+		{"byte limit just too small", 0, 2*entry - 1, []string{ten("a"), ten("b"), ten("c")}, []string{ten("c")}},
+		{"tightest limit wins", 5, entry, []string{ten("a"), ten("b"), ten("c")}, []string{ten("c")}},
+		{"oversized entry is kept", 0, 10, []string{ten("a"), ten("b")}, []string{ten("b")}},
+		{"nothing pushed", 2, 2 * entry, nil, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRingBuffer(test.maxEntries, test.maxBytes)
+			if got := ringMessages(r, test.pushed); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("buffered %v, want %v", got, test.want)
+			}
+			if r.len() != len(test.want) || r.size != len(test.want)*entry {
+				t.Errorf("len %d and size %d do not match %d entries", r.len(), r.size, len(test.want))
+			}
+		})
+	}
+}
+
+func TestRingBufferKeepsOrderAcrossCompaction(t *testing.T) {
+	r := newRingBuffer(3, 0)
+	for i := 0; i < 1000; i++ {
+		r.push(Record{Message: fmt.Sprint(i)})
+	}
+	if got, want := messages(r.snapshot()), []string{"997", "998", "999"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v, want %v", got, want)
+	}
+	if len(r.entries) > 8 {
+		t.Errorf("backing array holds %d entries for 3 buffered ones", len(r.entries))
+	}
+}
+
+func TestRingBufferDrain(t *testing.T) {
+	r := newRingBuffer(0, 0)
+	ringMessages(r, []string{"a", "b"})
+
+	if got, want := messages(r.drain()), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("drained %v, want %v", got, want)
+	}
+	if r.len() != 0 || r.size != 0 {
+		t.Errorf("buffer holds %d entries of %d bytes after draining", r.len(), r.size)
+	}
+	if got, want := ringMessages(r, []string{"c"}), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v after draining, want %v", got, want)
+	}
+}
+
+func TestRingBufferRequeue(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		requeued   []string
+		want       []string
+	}{
+		{"in front of the buffered entries", 0, []string{"a", "b"}, []string{"a", "b", "c", "d"}},
+		{"oldest requeued entries are dropped first", 3, []string{"a", "b"}, []string{"b", "c", "d"}},
+		{"buffered entries win over requeued ones", 2, []string{"a", "b"}, []string{"c", "d"}},
+		{"nothing requeued", 2, nil, []string{"c", "d"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRingBuffer(test.maxEntries, 0)
+			ringMessages(r, []string{"c", "d"})
+
+			var records []Record
+			for _, message := range test.requeued {
+				records = append(records, Record{Message: message})
+			}
+			r.requeue(records)
+			if got := messages(r.snapshot()); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("buffered %v, want %v", got, test.want)
+			}
+			if r.size != len(test.want)*recordOverhead+len(test.want) {
+				t.Errorf("size %d does not match %d entries", r.size, len(test.want))
+			}
+		})
+	}
+}
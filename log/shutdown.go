@@ -0,0 +1,81 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//ExitFlushTimeout is the time the exit handler of a registry gets to flush its buffers
+var ExitFlushTimeout = 5 * time.Second
+
+func init() {
+	defaultRegistry.RegisterExitHandler()
+}
+
+//SetFlushAllOnShutdown makes Shutdown flush every buffer, also the ones without pending errors
+func (r *Registry) SetFlushAllOnShutdown(all bool) {
+	r.mu.Lock()
+	r.flushAllOnShutdown = all
+	r.mu.Unlock()
+}
+
+//Shutdown flushes every buffer with pending errors, or every buffer if SetFlushAllOnShutdown was set,
+//and closes the fluentd connections of the registry
+//It returns ctx.Err() if ctx is done before every buffer was flushed, otherwise the first flush error
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	all := r.flushAllOnShutdown
+	buffers := r.allLocked()
+	r.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var firstErr error
+		for _, logFile := range buffers {
+			if ctx.Err() != nil {
+				break
+			}
+			logFile.WaitStreamed()
+			if !all && !logFile.ErrorHappened() {
+				continue
+			}
+			logFile.MarkError()
+			if _, err := logFile.Flush(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		done <- firstErr
+	}()
+
+	select {
+	case err := <-done:
+		if closeErr := r.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//Shutdown flushes the buffers of the default registry, see Registry.Shutdown
+func Shutdown(ctx context.Context) error {
+	return defaultRegistry.Shutdown(ctx)
+}
+
+//RegisterExitHandler makes logrus.Fatal, and every other logrus exit, call Shutdown with ExitFlushTimeout first
+//The default registry registers itself when the package is loaded, calling it again has no effect.
+//A direct os.Exit skips the logrus exit handlers, call Shutdown before it.
+func (r *Registry) RegisterExitHandler() {
+	r.exitHandlerOnce.Do(func() {
+		logrus.RegisterExitHandler(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), ExitFlushTimeout)
+			defer cancel()
+			if err := r.Shutdown(ctx); err != nil {
+				Diagnostics.Error("Flushing buffers before exit: ", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+//newShutdownTest creates a registry with the buffers marked, holding an error, and clean, holding only info
+func newShutdownTest(t *testing.T, sink Sink) *Registry {
+	t.Helper()
+	registry := NewRegistry(0)
+	for _, info := range []string{"marked", "clean"} {
+		_, logger := registry.CreateLogBuffer("service", info, 0, "", WithSink(sink))
+		logger.Logger.SetOutput(ioutil.Discard)
+		logger.Info(info)
+	}
+	marked, logger := registry.GetLogBufferAndLogger("service", "marked")
+	logger.Error("boom")
+	if !marked.ErrorHappened() {
+		t.Fatal("error did not mark the buffer")
+	}
+	return registry
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		all     bool
+		flushed []string
+	}{
+		{"pending buffers", false, []string{"boom", "marked"}},
+		{"all buffers", true, []string{"boom", "clean", "marked"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := &MemorySink{}
+			registry := newShutdownTest(t, sink)
+			registry.SetFlushAllOnShutdown(test.all)
+
+			if err := registry.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			flushed := messages(sink.Records())
+			sort.Strings(flushed)
+			if !reflect.DeepEqual(flushed, test.flushed) {
+				t.Errorf("flushed %v, want %v", flushed, test.flushed)
+			}
+			for _, batch := range sink.Batches() {
+				if batch.Reason != FlushReasonError {
+					t.Errorf("flushed with reason %v", batch.Reason)
+				}
+			}
+		})
+	}
+}
+
+//stalledSink holds every batch until release is closed
+type stalledSink struct {
+	MemorySink
+	release chan struct{}
+}
+
+func (s *stalledSink) Send(batch Batch) (int, error) {
+	<-s.release
+	return s.MemorySink.Send(batch)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	sink := &stalledSink{release: make(chan struct{})}
+	defer close(sink.release)
+	registry := newShutdownTest(t, sink)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := registry.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Shutdown took %v past its deadline", elapsed)
+	}
+}
@@ -0,0 +1,190 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//FlushReason tells why a batch was flushed
+type FlushReason string
+
+const (
+	//FlushReasonError is the reason of a flush after an error marked the buffer
+	FlushReasonError FlushReason = "error"
+	//FlushReasonDump is the reason of a dump requested by hand, e.g. through a signal
+	FlushReasonDump FlushReason = "dump"
+	//FlushReasonPostTrigger is the reason of the entries streamed in the post-trigger window
+	FlushReasonPostTrigger FlushReason = "post_trigger"
+)
+
+//FlushReasonKey is the field the flush reason is added under in the records sent by the sinks
+var FlushReasonKey = "flush_reason"
+
+//Batch is the set of records flushed from one buffer
+type Batch struct {
+	//Tag is serviceName.serviceInfo, used to route the records, e.g. by fluentd
+	Tag         string
+	ServiceName string
+	ServiceInfo string
+	Reason      FlushReason
+	Records     []Record
+}
+
+//recordData returns a record of the batch as a flat map, tagged with the flush reason
+func (b Batch) recordData(record Record) map[string]interface{} {
+	data := record.Data()
+	if b.Reason != "" {
+		data[FlushReasonKey] = b.Reason
+	}
+	return data
+}
+
+//Sink receives the records of a buffer when it is flushed
+type Sink interface {
+	//Send delivers the records of batch and returns how many of them were delivered
+	Send(batch Batch) (int, error)
+	//Destination describes where the sink delivers to, e.g. an address
+	Destination() string
+}
+
+//FluentSink sends records to fluentd using the forward protocol
+//Every record is sent with the time it was logged as event time, with sub-second precision
+type FluentSink struct {
+	keepTimeField bool
+
+	client *fluentClient
+}
+
+//FluentSinkOption configures a FluentSink when it is created
+type FluentSinkOption func(*FluentSink)
+
+//WithFluentTimeField also keeps the time of a record under "time" inside the record, formatted as RFC3339 with nanoseconds
+func WithFluentTimeField() FluentSinkOption {
+	return func(s *FluentSink) {
+		s.keepTimeField = true
+	}
+}
+
+//NewFluentSink creates a sink with its own connection to the fluentd at host and port
+//Buffers created by a Registry share one connection per host and port instead, see Registry.FluentSink
+func NewFluentSink(host string, port int, opts ...FluentSinkOption) *FluentSink {
+	return newFluentSink(newFluentClient(host, port), opts)
+}
+
+//newFluentSink creates a sink sending over client
+func newFluentSink(client *fluentClient, opts []FluentSinkOption) *FluentSink {
+	sink := &FluentSink{client: client}
+	for _, opt := range opts {
+		opt(sink)
+	}
+	return sink
+}
+
+//Send posts every record to fluentd, it stops at the first transport failure
+func (s *FluentSink) Send(batch Batch) (int, error) {
+	for i, record := range batch.Records {
+		data := s.recordData(batch, record)
+		tm := record.Time
+		if tm.IsZero() {
+			tm = time.Now()
+		}
+		if err := s.client.post(batch.Tag, tm, data); err != nil {
+			return i, err
+		}
+	}
+	return len(batch.Records), nil
+}
+
+//recordData returns the record as it is posted, the time is only kept in it with WithFluentTimeField
+func (s *FluentSink) recordData(batch Batch, record Record) map[string]interface{} {
+	data := batch.recordData(record)
+	if s.keepTimeField {
+		data[logrus.FieldKeyTime] = record.Time.Format(time.RFC3339Nano)
+	} else {
+		delete(data, logrus.FieldKeyTime)
+	}
+	return data
+}
+
+//Destination returns host:port of the fluentd
+func (s *FluentSink) Destination() string {
+	return s.client.address()
+}
+
+//Close closes the connection to fluentd, a later Send connects again
+func (s *FluentSink) Close() error {
+	return s.client.Close()
+}
+
+//WriterSink writes records as JSON lines to an io.Writer, e.g. os.Stderr
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+//NewWriterSink creates a sink writing to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+//Send writes one JSON line per record, with the tag of the batch under "tag"
+func (s *WriterSink) Send(batch Batch) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoder := json.NewEncoder(s.w)
+	for i, record := range batch.Records {
+		data := batch.recordData(record)
+		data["tag"] = batch.Tag
+		if err := encoder.Encode(data); err != nil {
+			return i, err
+		}
+	}
+	return len(batch.Records), nil
+}
+
+//Destination returns "writer"
+func (s *WriterSink) Destination() string {
+	return "writer"
+}
+
+//MemorySink keeps every batch it receives in memory, it is meant for tests
+type MemorySink struct {
+	mu      sync.Mutex
+	batches []Batch
+}
+
+//Send stores batch
+func (s *MemorySink) Send(batch Batch) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, batch)
+	return len(batch.Records), nil
+}
+
+//Destination returns "memory"
+func (s *MemorySink) Destination() string {
+	return "memory"
+}
+
+//Batches returns the batches received so far
+func (s *MemorySink) Batches() []Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Batch(nil), s.batches...)
+}
+
+//Records returns the records of every batch received so far
+func (s *MemorySink) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []Record
+	for _, batch := range s.batches {
+		records = append(records, batch.Records...)
+	}
+	return records
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFluentSinkTimeField(t *testing.T) {
+	logged := time.Date(2019, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	batch := Batch{Reason: FlushReasonError, Records: []Record{{Time: logged, Message: "boom"}}}
+
+	data := NewFluentSink("localhost", 24224).recordData(batch, batch.Records[0])
+	if _, ok := data[logrus.FieldKeyTime]; ok {
+		t.Errorf("time kept in the record by default: %v", data)
+	}
+
+	data = NewFluentSink("localhost", 24224, WithFluentTimeField()).recordData(batch, batch.Records[0])
+	if got, want := data[logrus.FieldKeyTime], "2019-06-01T12:00:00.123456789Z"; got != want {
+		t.Errorf("kept time %v, want %v", got, want)
+	}
+	if data[FlushReasonKey] != FlushReasonError || data[logrus.FieldKeyMsg] != "boom" {
+		t.Errorf("posted %v", data)
+	}
+}
+
+func TestRegistryFluentSinkSharing(t *testing.T) {
+	registry := NewRegistry(0)
+	plain := registry.FluentSink("localhost", 24224)
+	timed := registry.FluentSink("localhost", 24224, WithFluentTimeField())
+
+	if registry.FluentSink("localhost", 24224) != plain {
+		t.Error("plain sink not shared")
+	}
+	if registry.FluentSink("localhost", 24224, WithFluentTimeField()) != timed {
+		t.Error("sink with time field not shared")
+	}
+	if timed == plain || !timed.keepTimeField || plain.keepTimeField {
+		t.Errorf("options not kept apart: plain %+v, timed %+v", plain, timed)
+	}
+	if timed.client != plain.client {
+		t.Error("sinks to the same fluentd use their own connection")
+	}
+	if other := registry.FluentSink("localhost", 24225, WithFluentTimeField()); other.client == timed.client {
+		t.Error("sinks to another port share the connection")
+	}
+
+	logFile, _ := registry.CreateLogBuffer("svc", "info", 24224, "localhost")
+	if logFile.sink != plain {
+		t.Errorf("buffer sink %v, want the shared plain sink", logFile.sink)
+	}
+}
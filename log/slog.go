@@ -0,0 +1,130 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+//SlogHandler is a slog.Handler that captures records into an LFile, with the same trigger and flush behaviour as the logrus logger
+//Records are passed on to an inner handler for live output
+type SlogHandler struct {
+	logFile *LFile
+	inner   slog.Handler
+	level   slog.Leveler
+	//fields holds the attributes added with WithAttrs, keyed by their full dotted name
+	fields map[string]interface{}
+	//prefix is the dotted name of the open groups, e.g. "request."
+	prefix string
+}
+
+//NewSlogHandler creates a handler capturing records at level or above into logFile, a nil level captures from slog.LevelDebug
+//Records are also handled by inner, if it is not nil and enabled for their level
+func NewSlogHandler(logFile *LFile, inner slog.Handler, level slog.Leveler) *SlogHandler {
+	if level == nil {
+		level = slog.LevelDebug
+	}
+	return &SlogHandler{logFile: logFile, inner: inner, level: level}
+}
+
+//Enabled reports whether the buffer or the inner handler wants records at level
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() || (h.inner != nil && h.inner.Enabled(ctx, level))
+}
+
+//Handle captures record into the buffer and passes it on to the inner handler
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= h.level.Level() {
+		fields := make(map[string]interface{}, len(h.fields)+record.NumAttrs())
+		for key, value := range h.fields {
+			fields[key] = value
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			addSlogAttr(fields, h.prefix, attr)
+			return true
+		})
+		h.logFile.Capture(Record{
+			Time:    record.Time,
+			Level:   logrusLevel(record.Level),
+			Message: record.Message,
+			Fields:  fields,
+		})
+	}
+
+	if h.inner != nil && h.inner.Enabled(ctx, record.Level) {
+		return h.inner.Handle(ctx, record)
+	}
+	return nil
+}
+
+//WithAttrs returns a handler adding attrs to every record
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	clone.fields = make(map[string]interface{}, len(h.fields)+len(attrs))
+	for key, value := range h.fields {
+		clone.fields[key] = value
+	}
+	for _, attr := range attrs {
+		addSlogAttr(clone.fields, h.prefix, attr)
+	}
+	if h.inner != nil {
+		clone.inner = h.inner.WithAttrs(attrs)
+	}
+	return &clone
+}
+
+//WithGroup returns a handler nesting the attributes of later records in the group name
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	if h.inner != nil {
+		clone.inner = h.inner.WithGroup(name)
+	}
+	return &clone
+}
+
+//addSlogAttr adds attr to fields, groups are flattened into dotted keys
+func addSlogAttr(fields map[string]interface{}, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		group := value.Group()
+		if len(group) == 0 {
+			return
+		}
+		//A group without key is inlined
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range group {
+			addSlogAttr(fields, prefix, member)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+	fields[prefix+attr.Key] = value.Any()
+}
+
+//logrusLevel maps a slog level onto the closest logrus level
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
@@ -0,0 +1,131 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSlogHandlerGroups(t *testing.T) {
+	logFile, _, _ := newTestBuffer(t)
+	handler := NewSlogHandler(logFile, nil, nil).
+		WithAttrs([]slog.Attr{slog.String("service", "api")}).
+		WithGroup("request").
+		WithAttrs([]slog.Attr{slog.Int("id", 7)}).
+		WithGroup("")
+
+	slog.New(handler).Info("handled",
+		"path", "/users",
+		slog.Group("user", "name", "bob", slog.Group("role", "admin", true)),
+		slog.Group("", "inline", 1.5),
+		slog.Group("empty"),
+	)
+
+	records := logFile.Records()
+	if len(records) != 1 {
+		t.Fatalf("buffered %d records, want 1", len(records))
+	}
+	want := map[string]interface{}{
+		"service":                 "api",
+		"request.id":              int64(7),
+		"request.path":            "/users",
+		"request.user.name":       "bob",
+		"request.user.role.admin": true,
+		"request.inline":          1.5,
+	}
+	if got := records[0].Fields; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields %v, want %v", got, want)
+	}
+	if records[0].Message != "handled" || records[0].Level != logrus.InfoLevel {
+		t.Errorf("buffered %+v", records[0])
+	}
+}
+
+func TestSlogHandlerAttrsDoNotLeak(t *testing.T) {
+	logFile, _, _ := newTestBuffer(t)
+	parent := NewSlogHandler(logFile, nil, nil)
+	child := parent.WithGroup("child").WithAttrs([]slog.Attr{slog.String("a", "1")})
+
+	slog.New(child).Info("child")
+	slog.New(parent).Info("parent")
+
+	records := logFile.Records()
+	if got, want := records[0].Fields, map[string]interface{}{"child.a": "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("child fields %v, want %v", got, want)
+	}
+	if got := records[1].Fields; len(got) != 0 {
+		t.Errorf("parent fields %v, want none", got)
+	}
+}
+
+func TestLogrusLevel(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  logrus.Level
+	}{
+		{slog.LevelDebug - 4, logrus.TraceLevel},
+		{slog.LevelDebug, logrus.DebugLevel},
+		{slog.LevelInfo - 1, logrus.DebugLevel},
+		{slog.LevelInfo, logrus.InfoLevel},
+		{slog.LevelInfo + 2, logrus.InfoLevel},
+		{slog.LevelWarn, logrus.WarnLevel},
+		{slog.LevelError, logrus.ErrorLevel},
+		{slog.LevelError + 4, logrus.ErrorLevel},
+	}
+	for _, test := range tests {
+		if got := logrusLevel(test.level); got != test.want {
+			t.Errorf("logrusLevel(%v) = %v, want %v", test.level, got, test.want)
+		}
+	}
+}
+
+func TestSlogHandlerLevel(t *testing.T) {
+	logFile, _, _ := newTestBuffer(t)
+	var out bytes.Buffer
+	inner := slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+	handler := NewSlogHandler(logFile, nil, slog.LevelInfo)
+	if handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("handler without inner handler enabled below its level")
+	}
+	handler = NewSlogHandler(logFile, inner, slog.LevelInfo)
+	if !handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("handler not enabled for the level of its inner handler")
+	}
+
+	logger := slog.New(handler)
+	logger.Debug("debug")
+	logger.Info("info")
+
+	if got, want := messages(logFile.Records()), []string{"info"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v, want %v", got, want)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(`"msg":"debug"`)) || !bytes.Contains(out.Bytes(), []byte(`"msg":"info"`)) {
+		t.Errorf("inner handler printed %s", out.String())
+	}
+}
+
+func TestSlogHandlerErrorMarksBuffer(t *testing.T) {
+	logFile, _, sink := newTestBuffer(t)
+	logger := slog.New(NewSlogHandler(logFile, nil, nil))
+
+	logger.Info("context")
+	if logFile.ErrorHappened() {
+		t.Fatal("info record marked the buffer")
+	}
+	logger.Error("boom")
+	if !logFile.ErrorHappened() {
+		t.Fatal("error record did not mark the buffer")
+	}
+
+	if _, err := logFile.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := messages(sink.Records()), []string{"context", "boom"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flushed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//Trigger decides whether a record marks its buffer for flushing
+type Trigger interface {
+	Match(record Record) bool
+}
+
+//TriggerFunc turns a function into a Trigger, for custom predicates
+type TriggerFunc func(record Record) bool
+
+//Match calls f
+func (f TriggerFunc) Match(record Record) bool {
+	return f(record)
+}
+
+//TriggerPolicy marks a buffer as soon as any of its triggers matches a record
+//It is evaluated for every entry written to the buffer
+type TriggerPolicy []Trigger
+
+//Match reports whether any trigger of the policy matches record
+func (p TriggerPolicy) Match(record Record) bool {
+	for _, trigger := range p {
+		if trigger.Match(record) {
+			return true
+		}
+	}
+	return false
+}
+
+//statefulTrigger is a Trigger that keeps state between records, like RateTrigger
+type statefulTrigger interface {
+	Trigger
+	//reset returns a trigger with the same settings and no state
+	reset() Trigger
+}
+
+//forChild returns a copy of the policy for a child buffer, with its own state for every statefulTrigger
+func (p TriggerPolicy) forChild() TriggerPolicy {
+	if p == nil {
+		return nil
+	}
+	child := make(TriggerPolicy, len(p))
+	for i, trigger := range p {
+		if stateful, ok := trigger.(statefulTrigger); ok {
+			trigger = stateful.reset()
+		}
+		child[i] = trigger
+	}
+	return child
+}
+
+//DefaultTriggerPolicy marks a buffer on every entry logged at Error level or above
+func DefaultTriggerPolicy() TriggerPolicy {
+	return TriggerPolicy{LevelTrigger(logrus.ErrorLevel)}
+}
+
+//LevelTrigger matches records logged at level or above, e.g. Error also matches Fatal and Panic
+func LevelTrigger(level logrus.Level) Trigger {
+	return TriggerFunc(func(record Record) bool {
+		return record.Level <= level
+	})
+}
+
+//MessageTrigger matches records whose message matches pattern
+func MessageTrigger(pattern *regexp.Regexp) Trigger {
+	return TriggerFunc(func(record Record) bool {
+		return pattern.MatchString(record.Message)
+	})
+}
+
+//fieldOperators are the comparisons FieldTrigger understands, two-character operators first
+var fieldOperators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+//FieldTrigger matches records with a field satisfying expression, e.g. "http_status>=500" or "component==db"
+//The operators are ==, =, !=, >, >=, < and <=. Values are compared as numbers if both sides are numeric,
+//otherwise only == and != apply and compare the values as text
+func FieldTrigger(expression string) (Trigger, error) {
+	//The operator starts at the first operator character, the field name may not contain any
+	i := strings.IndexAny(expression, "<>!=")
+	for _, operator := range fieldOperators {
+		if i <= 0 || !strings.HasPrefix(expression[i:], operator) {
+			continue
+		}
+		key := strings.TrimSpace(expression[:i])
+		expected := strings.TrimSpace(expression[i+len(operator):])
+		if key == "" || expected == "" {
+			break
+		}
+		if operator == "=" {
+			operator = "=="
+		}
+		return fieldTrigger{key, operator, expected}, nil
+	}
+	return nil, fmt.Errorf("invalid field trigger %q, expected <field><operator><value>", expression)
+}
+
+//fieldTrigger compares one field of a record to a value
+type fieldTrigger struct {
+	key      string
+	operator string
+	expected string
+}
+
+func (t fieldTrigger) Match(record Record) bool {
+	value, ok := record.Fields[t.key]
+	if !ok {
+		return false
+	}
+	actual := fmt.Sprint(value)
+
+	left, leftErr := strconv.ParseFloat(actual, 64)
+	right, rightErr := strconv.ParseFloat(t.expected, 64)
+	if leftErr == nil && rightErr == nil {
+		switch t.operator {
+		case "==":
+			return left == right
+		case "!=":
+			return left != right
+		case ">":
+			return left > right
+		case ">=":
+			return left >= right
+		case "<":
+			return left < right
+		case "<=":
+			return left <= right
+		}
+	}
+
+	switch t.operator {
+	case "==":
+		return actual == t.expected
+	case "!=":
+		return actual != t.expected
+	}
+	return false
+}
+
+//RateTrigger matches once n records at level or above were logged within window, e.g. 10 warnings within a minute
+//The returned trigger keeps state, it should not be shared between buffers. Child buffers get their own copy,
+//so the records of one request are counted apart from the parent and the other requests.
+func RateTrigger(level logrus.Level, n int, window time.Duration) Trigger {
+	return &rateTrigger{level: level, n: n, window: window}
+}
+
+//rateTrigger remembers the times of the recent records at its level
+type rateTrigger struct {
+	level  logrus.Level
+	n      int
+	window time.Duration
+
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (t *rateTrigger) reset() Trigger {
+	return &rateTrigger{level: t.level, n: t.n, window: t.window}
+}
+
+func (t *rateTrigger) Match(record Record) bool {
+	if record.Level > t.level {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	//Forget the records that fell out of the window
+	cutoff := record.Time.Add(-t.window)
+	kept := t.times[:0]
+	for _, seen := range t.times {
+		if seen.After(cutoff) {
+			kept = append(kept, seen)
+		}
+	}
+	t.times = append(kept, record.Time)
+
+	if len(t.times) < t.n {
+		return false
+	}
+	//Start counting again, so one burst triggers once
+	t.times = t.times[:0]
+	return true
+}
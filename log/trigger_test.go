@@ -0,0 +1,160 @@
+package log
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFieldTriggerParsing(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{"http_status>=500", false},
+		{"http_status >= 500", false},
+		{"component==db", false},
+		{"component=db", false},
+		{"component!=db", false},
+		{"latency<0.5", false},
+		{"", true},
+		{"http_status", true},
+		{">=500", true},
+		{"http_status>=", true},
+		{"  ==db", true},
+	}
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := FieldTrigger(test.expression)
+			if (err != nil) != test.wantErr {
+				t.Errorf("FieldTrigger(%q) returned error %v, want error %v", test.expression, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestFieldTriggerMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		fields     logrus.Fields
+		want       bool
+	}{
+		{"http_status>=500", logrus.Fields{"http_status": 500}, true},
+		{"http_status>=500", logrus.Fields{"http_status": 404}, false},
+		{"http_status>=500", logrus.Fields{"http_status": "503"}, true},
+		{"http_status>500", logrus.Fields{"http_status": 500}, false},
+		{"http_status<400", logrus.Fields{"http_status": 200}, true},
+		{"http_status<=400", logrus.Fields{"http_status": 400}, true},
+		{"latency>0.5", logrus.Fields{"latency": 0.75}, true},
+		{"retries==3", logrus.Fields{"retries": 3.0}, true},
+		{"retries!=3", logrus.Fields{"retries": 3}, false},
+		{"component==db", logrus.Fields{"component": "db"}, true},
+		{"component=db", logrus.Fields{"component": "db"}, true},
+		{"component!=db", logrus.Fields{"component": "cache"}, true},
+		{"component==db", logrus.Fields{"component": "cache"}, false},
+		{"component>db", logrus.Fields{"component": "dc"}, false},
+		{"http_status>=500", logrus.Fields{"other": 500}, false},
+		{"http_status>=500", nil, false},
+	}
+	for _, test := range tests {
+		trigger, err := FieldTrigger(test.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := trigger.Match(Record{Fields: test.fields}); got != test.want {
+			t.Errorf("%s matched %v: %v, want %v", test.expression, test.fields, got, test.want)
+		}
+	}
+}
+
+func TestLevelAndMessageTriggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger Trigger
+		record  Record
+		want    bool
+	}{
+		{"level matches itself", LevelTrigger(logrus.WarnLevel), Record{Level: logrus.WarnLevel}, true},
+		{"level matches more severe", LevelTrigger(logrus.WarnLevel), Record{Level: logrus.ErrorLevel}, true},
+		{"level skips less severe", LevelTrigger(logrus.WarnLevel), Record{Level: logrus.InfoLevel}, false},
+		{"message matches", MessageTrigger(regexp.MustCompile(`timeout|deadline`)), Record{Message: "read timeout"}, true},
+		{"message skips", MessageTrigger(regexp.MustCompile(`timeout|deadline`)), Record{Message: "ok"}, false},
+		{"default policy", DefaultTriggerPolicy(), Record{Level: logrus.ErrorLevel}, true},
+		{"empty policy", TriggerPolicy{}, Record{Level: logrus.PanicLevel}, false},
+	}
+	for _, test := range tests {
+		if got := test.trigger.Match(test.record); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRateTrigger(t *testing.T) {
+	base := time.Date(2019, 5, 23, 10, 0, 0, 0, time.UTC)
+	at := func(seconds int, level logrus.Level) Record {
+		return Record{Time: base.Add(time.Duration(seconds) * time.Second), Level: level}
+	}
+
+	tests := []struct {
+		name    string
+		records []Record
+		//want holds the result of Match for every record
+		want []bool
+	}{
+		{
+			"burst within the window",
+			[]Record{at(0, logrus.WarnLevel), at(1, logrus.WarnLevel), at(2, logrus.WarnLevel)},
+			[]bool{false, false, true},
+		},
+		{
+			"spread beyond the window",
+			[]Record{at(0, logrus.WarnLevel), at(40, logrus.WarnLevel), at(80, logrus.WarnLevel), at(120, logrus.WarnLevel)},
+			[]bool{false, false, false, false},
+		},
+		{
+			"lower levels do not count",
+			[]Record{at(0, logrus.WarnLevel), at(1, logrus.InfoLevel), at(2, logrus.DebugLevel), at(3, logrus.ErrorLevel)},
+			[]bool{false, false, false, false},
+		},
+		{
+			"counting restarts after a match",
+			[]Record{at(0, logrus.ErrorLevel), at(1, logrus.ErrorLevel), at(2, logrus.ErrorLevel), at(3, logrus.ErrorLevel), at(4, logrus.ErrorLevel), at(5, logrus.ErrorLevel)},
+			[]bool{false, false, true, false, false, true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trigger := RateTrigger(logrus.WarnLevel, 3, time.Minute)
+			for i, record := range test.records {
+				if got := trigger.Match(record); got != test.want[i] {
+					t.Errorf("record %d matched %v, want %v", i, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateTriggerPerChild(t *testing.T) {
+	parent, logger, _ := newTestBuffer(t, WithTriggerPolicy(TriggerPolicy{RateTrigger(logrus.WarnLevel, 2, time.Minute)}))
+	first := parent.NewChild(logrus.Fields{"request": 1})
+	second := parent.NewChild(logrus.Fields{"request": 2})
+	defer first.Finish()
+	defer second.Finish()
+
+	logger.Warn("parent")
+	first.Logger().Warn("first")
+	second.Logger().Warn("second")
+	if parent.ErrorHappened() || first.ErrorHappened() || second.ErrorHappened() {
+		t.Fatal("warnings of different buffers were counted together")
+	}
+
+	first.Logger().Warn("first again")
+	if !first.ErrorHappened() {
+		t.Error("two warnings of one child did not trigger")
+	}
+	if parent.ErrorHappened() || second.ErrorHappened() {
+		t.Error("a burst in one child marked another buffer")
+	}
+}
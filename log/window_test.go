@@ -0,0 +1,266 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//reasons returns the messages sent per flush reason
+func reasons(sink *MemorySink) map[FlushReason][]string {
+	sent := make(map[FlushReason][]string)
+	for _, batch := range sink.Batches() {
+		sent[batch.Reason] = append(sent[batch.Reason], messages(batch.Records)...)
+	}
+	return sent
+}
+
+func TestPreTriggerWindow(t *testing.T) {
+	base := time.Now()
+	at := func(seconds int, level logrus.Level, message string) Record {
+		return Record{Time: base.Add(time.Duration(seconds) * time.Second), Level: level, Message: message}
+	}
+
+	tests := []struct {
+		name     string
+		entries  int
+		duration time.Duration
+		records  []Record
+		want     []string
+	}{
+		{
+			"unbounded",
+			0, 0,
+			[]Record{at(0, logrus.InfoLevel, "a"), at(1, logrus.InfoLevel, "b"), at(2, logrus.ErrorLevel, "boom")},
+			[]string{"a", "b", "boom"},
+		},
+		{
+			"last entries",
+			1, 0,
+			[]Record{at(0, logrus.InfoLevel, "a"), at(1, logrus.InfoLevel, "b"), at(2, logrus.ErrorLevel, "boom")},
+			[]string{"b", "boom"},
+		},
+		{
+			"last seconds",
+			0, 5 * time.Second,
+			[]Record{at(0, logrus.InfoLevel, "a"), at(6, logrus.InfoLevel, "b"), at(10, logrus.ErrorLevel, "boom")},
+			[]string{"b", "boom"},
+		},
+		{
+			"both limits",
+			2, 5 * time.Second,
+			[]Record{at(6, logrus.InfoLevel, "a"), at(7, logrus.InfoLevel, "b"), at(8, logrus.InfoLevel, "c"), at(10, logrus.ErrorLevel, "boom")},
+			[]string{"b", "c", "boom"},
+		},
+		{
+			"entries after the trigger are kept",
+			1, 0,
+			[]Record{at(0, logrus.InfoLevel, "a"), at(1, logrus.InfoLevel, "b"), at(2, logrus.ErrorLevel, "boom"), at(3, logrus.InfoLevel, "c"), at(4, logrus.InfoLevel, "d")},
+			[]string{"b", "boom", "c", "d"},
+		},
+		{
+			"only the first trigger trims",
+			1, 0,
+			[]Record{at(0, logrus.InfoLevel, "a"), at(1, logrus.InfoLevel, "b"), at(2, logrus.ErrorLevel, "boom"), at(3, logrus.InfoLevel, "c"), at(4, logrus.ErrorLevel, "again")},
+			[]string{"b", "boom", "c", "again"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logFile, _, sink := newTestBuffer(t, WithPreTriggerWindow(test.entries, test.duration))
+			for _, record := range test.records {
+				logFile.Capture(record)
+			}
+			if _, err := logFile.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := messages(sink.Records()); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("sent %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPostTriggerWindowEntries(t *testing.T) {
+	logFile, logger, sink := newTestBuffer(t, WithPostTriggerWindow(2, 0))
+
+	logger.Info("before")
+	logger.Error("boom")
+	for i := 0; i < 4; i++ {
+		logger.Info("after ", i)
+	}
+
+	want := map[FlushReason][]string{
+		FlushReasonError:       {"before", "boom"},
+		FlushReasonPostTrigger: {"after 0", "after 1"},
+	}
+	logFile.WaitStreamed()
+	if got := reasons(sink); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	//After the window the buffer goes back to buffering
+	if got, want := messages(logFile.Records()), []string{"after 2", "after 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v, want %v", got, want)
+	}
+	if logFile.ErrorHappened() {
+		t.Error("buffer is still marked after the automatic flush")
+	}
+}
+
+func TestPostTriggerWindowDuration(t *testing.T) {
+	logFile, logger, sink := newTestBuffer(t, WithPostTriggerWindow(0, 50*time.Millisecond))
+
+	logger.Error("boom")
+	logger.Info("inside")
+	time.Sleep(100 * time.Millisecond)
+	logger.Info("outside")
+
+	want := map[FlushReason][]string{
+		FlushReasonError:       {"boom"},
+		FlushReasonPostTrigger: {"inside"},
+	}
+	logFile.WaitStreamed()
+	if got := reasons(sink); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	if got, want := messages(logFile.Records()), []string{"outside"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v, want %v", got, want)
+	}
+}
+
+func TestPostTriggerWindowRestartsOnTrigger(t *testing.T) {
+	logFile, logger, sink := newTestBuffer(t, WithPostTriggerWindow(2, 0))
+
+	logger.Error("boom")
+	logger.Info("after 0")
+	logger.Error("again")
+	for i := 1; i < 4; i++ {
+		logger.Info(fmt.Sprint("after ", i))
+	}
+
+	want := map[FlushReason][]string{
+		FlushReasonError:       {"boom"},
+		FlushReasonPostTrigger: {"after 0", "again", "after 1", "after 2"},
+	}
+	logFile.WaitStreamed()
+	if got := reasons(sink); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	if got, want := messages(logFile.Records()), []string{"after 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v, want %v", got, want)
+	}
+}
+
+//blockingSink holds every post-trigger batch until release is closed
+type blockingSink struct {
+	MemorySink
+	streaming chan struct{}
+	release   chan struct{}
+}
+
+func (s *blockingSink) Send(batch Batch) (int, error) {
+	if batch.Reason == FlushReasonPostTrigger {
+		s.streaming <- struct{}{}
+		<-s.release
+	}
+	return s.MemorySink.Send(batch)
+}
+
+func TestPostTriggerWindowDoesNotWaitForSink(t *testing.T) {
+	defer func(size int) { MaxStreamQueue = size }(MaxStreamQueue)
+	MaxStreamQueue = 3
+	sink := &blockingSink{streaming: make(chan struct{}, 10), release: make(chan struct{})}
+	logFile, logger, _ := newTestBuffer(t, WithSink(sink), WithPostTriggerWindow(0, time.Minute))
+
+	logger.Error("boom")
+	logger.Info("after 0")
+	<-sink.streaming
+
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i < 6; i++ {
+			logger.Info("after ", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging waited for a stalled sink")
+	}
+	close(sink.release)
+	logFile.WaitStreamed()
+
+	var batches [][]string
+	for _, batch := range sink.Batches() {
+		if batch.Reason == FlushReasonPostTrigger {
+			batches = append(batches, messages(batch.Records))
+		}
+	}
+	//The entries waiting behind the stalled send go out together, the ones beyond the queue are dropped
+	if want := [][]string{{"after 0"}, {"after 1", "after 2", "after 3"}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("streamed %v, want %v", batches, want)
+	}
+}
+
+//waitLevel waits until logger runs at level, or fails the test after timeout
+func waitLevel(t *testing.T, logger *logrus.Entry, level logrus.Level, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for logger.Logger.GetLevel() != level {
+		if time.Now().After(deadline) {
+			t.Fatalf("logger at %v after %v, want %v", logger.Logger.GetLevel(), timeout, level)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEscalationRestoresLevel(t *testing.T) {
+	logFile, logger, sink := newTestBuffer(t, WithEscalation(logrus.DebugLevel, 50*time.Millisecond))
+
+	logger.Debug("before")
+	logger.Error("boom")
+	if got := logger.Logger.GetLevel(); got != logrus.DebugLevel {
+		t.Fatalf("logger at %v after the trigger, want debug", got)
+	}
+	logger.Debug("escalated")
+
+	waitLevel(t, logger, logrus.InfoLevel, 2*time.Second)
+	logger.Debug("after")
+	logger.Info("buffered")
+
+	want := map[FlushReason][]string{
+		FlushReasonError:       {"boom"},
+		FlushReasonPostTrigger: {"escalated"},
+	}
+	logFile.WaitStreamed()
+	if got := reasons(sink); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	if got, want := messages(logFile.Records()), []string{"buffered"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %v, want %v", got, want)
+	}
+}
+
+func TestEscalationRestartsOnTrigger(t *testing.T) {
+	cooldown := 400 * time.Millisecond
+	_, logger, _ := newTestBuffer(t, WithEscalation(logrus.DebugLevel, cooldown))
+
+	start := time.Now()
+	logger.Error("boom")
+	time.Sleep(cooldown * 5 / 8)
+	logger.Error("again")
+	time.Sleep(cooldown * 5 / 8)
+
+	//The first cooldown has ended, the one of the second trigger has not
+	if got := logger.Logger.GetLevel(); got != logrus.DebugLevel {
+		t.Fatalf("logger at %v %v after the first trigger, want debug", got, time.Since(start))
+	}
+	waitLevel(t, logger, logrus.InfoLevel, 2*time.Second)
+	if elapsed := time.Since(start); elapsed < cooldown*5/8+cooldown {
+		t.Errorf("level restored %v after the first trigger, before the second cooldown ended", elapsed)
+	}
+}